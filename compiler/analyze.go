@@ -0,0 +1,158 @@
+package compiler
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// Unsupported is a construct in the Go source that the compiler cannot translate.
+type Unsupported struct {
+	Pos       token.Position
+	Construct string
+}
+
+func (u Unsupported) String() string {
+	return fmt.Sprintf("%s: %s", u.Pos, u.Construct)
+}
+
+// unsupportedPackages are the imported packages that have no Python translation.
+var unsupportedPackages = map[string]string{
+	"unsafe":  "package unsafe",
+	"reflect": "package reflect",
+}
+
+// Analyze reports the constructs in files that the compiler cannot translate,
+// in the order they appear, without compiling them.
+func (c *Compiler) Analyze(files []*ast.File) []Unsupported {
+	var unsupported []Unsupported
+	report := func(pos token.Pos, format string, args ...interface{}) {
+		var position token.Position
+		if c.FileSet != nil {
+			position = c.Position(pos)
+		}
+		unsupported = append(unsupported, Unsupported{Pos: position, Construct: fmt.Sprintf(format, args...)})
+	}
+	for _, file := range files {
+		// The names that files refers to unsupported packages by
+		packages := map[string]string{}
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.IMPORT {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				spec := spec.(*ast.ImportSpec)
+				path, _ := strconv.Unquote(spec.Path.Value)
+				if path == "C" {
+					// The cgo preamble is the comment on import "C"
+					pos := spec.Pos()
+					if spec.Doc != nil {
+						pos = spec.Doc.Pos()
+					} else if genDecl.Doc != nil && !genDecl.Lparen.IsValid() {
+						pos = genDecl.Doc.Pos()
+					}
+					report(pos, "cgo")
+					continue
+				}
+				if _, ok := unsupportedPackages[path]; !ok {
+					continue
+				}
+				name := path
+				if spec.Name != nil {
+					name = spec.Name.Name
+				}
+				packages[name] = path
+			}
+		}
+
+		// The gotos that cannot be compiled, found in the functions visited
+		unsupportedGoto := map[*ast.BranchStmt]bool{}
+		var visit func(node ast.Node) bool
+		visit = func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.BinaryExpr:
+				if c.isUintptr(n.X) && isArithmetic(n.Op) {
+					report(n.OpPos, "uintptr arithmetic")
+				}
+			case *ast.AssignStmt:
+				if len(n.Lhs) == 1 && c.isUintptr(n.Lhs[0]) && n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+					report(n.TokPos, "uintptr arithmetic")
+				}
+			case *ast.SelectorExpr:
+				if ident, ok := n.X.(*ast.Ident); ok && ident.Obj == nil {
+					if path, ok := packages[ident.Name]; ok {
+						report(n.Pos(), "%s: %s.%s", unsupportedPackages[path], ident.Name, n.Sel.Name)
+					}
+				}
+			case *ast.BranchStmt:
+				if unsupportedGoto[n] {
+					report(n.Pos(), "goto")
+				}
+			case *ast.FuncDecl:
+				if n.Body != nil {
+					for _, branch := range unsupportedGotos(n.Body) {
+						unsupportedGoto[branch] = true
+					}
+				}
+			case *ast.FuncLit:
+				for _, branch := range unsupportedGotos(n.Body) {
+					unsupportedGoto[branch] = true
+				}
+			case *ast.TypeSwitchStmt:
+				// A pointer to a struct compiles to the struct object, so
+				// the cases *T and T cannot be told apart
+				var cases []ast.Expr
+				for _, stmt := range n.Body.List {
+					for _, expr := range stmt.(*ast.CaseClause).List {
+						typ := c.TypeOf(expr)
+						if typ == nil {
+							continue
+						}
+						for _, other := range cases {
+							otherType := c.TypeOf(other)
+							if isPointerTo(typ, otherType) || isPointerTo(otherType, typ) {
+								report(expr.Pos(), "type switch on both %s and %s", types.ExprString(other), types.ExprString(expr))
+							}
+						}
+						cases = append(cases, expr)
+					}
+				}
+			}
+			return true
+		}
+		ast.Inspect(file, visit)
+	}
+	return unsupported
+}
+
+// isUintptr reports whether expr is a uintptr, which is compiled as an int
+// although its arithmetic is usually on pointers.
+func (c *Compiler) isUintptr(expr ast.Expr) bool {
+	t, ok := c.TypeOf(expr).(*types.Basic)
+	return ok && t.Kind() == types.Uintptr
+}
+
+// isArithmetic reports whether op is an arithmetic operator.
+func isArithmetic(op token.Token) bool {
+	switch op {
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+		token.AND, token.OR, token.XOR, token.SHL, token.SHR, token.AND_NOT:
+		return true
+	}
+	return false
+}
+
+// isSignature reports whether typ is a function type.
+func isSignature(typ types.Type) bool {
+	_, ok := typ.(*types.Signature)
+	return ok
+}
+
+// isPointerTo reports whether ptr is a pointer to elem.
+func isPointerTo(ptr, elem types.Type) bool {
+	p, ok := ptr.(*types.Pointer)
+	return ok && types.Identical(p.Elem(), elem)
+}
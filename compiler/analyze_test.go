@@ -0,0 +1,169 @@
+package compiler
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	const golang = `package main
+
+// #include <stdio.h>
+import "C"
+
+import "unsafe"
+
+func f(x *int) uintptr {
+	for {
+		goto end
+	}
+end:
+	return uintptr(unsafe.Pointer(x))
+}
+
+func g(unsafe int) int { return unsafe }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", golang, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range NewCompiler(&types.Info{}, fset).Analyze([]*ast.File{file}) {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"main.go:3:1: cgo",
+		"main.go:10:3: goto",
+		"main.go:13:17: package unsafe: unsafe.Pointer",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+// Arithmetic on a uintptr is reported although converting to one compiles to
+// the int
+func TestAnalyzeUintptr(t *testing.T) {
+	const golang = `package main
+
+func f(xs []int, p uintptr) uintptr {
+	n := uintptr(len(xs))
+	p += 8
+	return p + n
+}
+`
+	fset := token.NewFileSet()
+	pkg, file, errs := buildFileSet(fset, golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	var got []string
+	for _, u := range NewCompiler(&pkg.Info, fset).Analyze([]*ast.File{file}) {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"main.go:5:4: uintptr arithmetic",
+		"main.go:6:11: uintptr arithmetic",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+// The cases *T and T of a type switch are reported because a pointer to a
+// struct compiles to the struct object
+func TestAnalyzeTypeSwitchPointer(t *testing.T) {
+	const golang = `package main
+
+type T struct{ x int }
+type U struct{ x int }
+
+func f(v interface{}) int {
+	switch v := v.(type) {
+	case *T:
+		return v.x
+	case U, T:
+		return v.(T).x
+	case *U:
+		return v.x
+	}
+	return 0
+}
+`
+	fset := token.NewFileSet()
+	pkg, file, errs := buildFileSet(fset, golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	var got []string
+	for _, u := range NewCompiler(&pkg.Info, fset).Analyze([]*ast.File{file}) {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"main.go:10:10: type switch on both *T and T",
+		"main.go:12:7: type switch on both U and *U",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+// Named function types with methods compile to classes
+func TestAnalyzeFuncTypeMethod(t *testing.T) {
+	const golang = `package main
+
+type F func(int) int
+
+func (f F) twice(n int) int { return f(f(n)) }
+`
+	fset := token.NewFileSet()
+	pkg, file, errs := buildFileSet(fset, golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	var got []string
+	for _, u := range NewCompiler(&pkg.Info, fset).Analyze([]*ast.File{file}) {
+		got = append(got, u.String())
+	}
+	if len(got) != 0 {
+		t.Errorf("want no unsupported constructs, got %q", got)
+	}
+}
+
+// Only gotos out of loops or to labels in nested blocks are reported
+func TestAnalyzeGoto(t *testing.T) {
+	const golang = `package main
+
+func f(n int) int {
+start:
+	if n > 0 {
+		n--
+		goto start
+	}
+	if n < 0 {
+		goto inner
+	inner:
+		n++
+	}
+	return n
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", golang, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range NewCompiler(&types.Info{}, fset).Analyze([]*ast.File{file}) {
+		got = append(got, u.String())
+	}
+	want := []string{"main.go:10:3: goto"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
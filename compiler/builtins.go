@@ -9,9 +9,33 @@ var (
 	pyEmptyString = &py.Str{S: `""`}
 	pyRange       = &py.Name{Id: py.Identifier("range")}
 	pyLen         = &py.Name{Id: py.Identifier("len")}
+	pyMin         = &py.Name{Id: py.Identifier("min")}
 	pyEnumerate   = &py.Name{Id: py.Identifier("enumerate")}
 	pyType        = &py.Name{Id: py.Identifier("type")}
 	pyKeyError    = &py.Name{Id: py.Identifier("KeyError")}
+	pyException   = &py.Name{Id: py.Identifier("Exception")}
 	pyComplex     = &py.Name{Id: py.Identifier("complex")}
-	pyReversed    = &py.Name{Id: py.Identifier("reversed")}
+	pyPrint       = &py.Name{Id: py.Identifier("print")}
+	pyBytes       = &py.Name{Id: py.Identifier("bytes")}
+	pyStr         = &py.Name{Id: py.Identifier("str")}
+	pyInt         = &py.Name{Id: py.Identifier("int")}
+	pyFloat       = &py.Name{Id: py.Identifier("float")}
+	pyBool        = &py.Name{Id: py.Identifier("bool")}
+	pyList        = &py.Name{Id: py.Identifier("list")}
+	pyDict        = &py.Name{Id: py.Identifier("dict")}
+	pySet         = &py.Name{Id: py.Identifier("set")}
+	pyMap         = &py.Name{Id: py.Identifier("map")}
+	pyChr         = &py.Name{Id: py.Identifier("chr")}
+	pyOrd         = &py.Name{Id: py.Identifier("ord")}
+	pyHash        = &py.Name{Id: py.Identifier("hash")}
+	pyIsInstance  = &py.Name{Id: py.Identifier("isinstance")}
+	pySuper       = &py.Name{Id: py.Identifier("super")}
+	pyGlobals     = &py.Name{Id: py.Identifier("globals")}
+	pyVars        = &py.Name{Id: py.Identifier("vars")}
+	pyCallable    = &py.Name{Id: py.Identifier("callable")}
+	pyGetattr     = &py.Name{Id: py.Identifier("getattr")}
+	pyAll         = &py.Name{Id: py.Identifier("all")}
+	pyClassmethod = &py.Name{Id: py.Identifier("classmethod")}
+
+	pyNotImplemented = &py.Name{Id: py.Identifier("NotImplemented")}
 )
@@ -6,6 +6,8 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path"
+	"reflect"
 	"strconv"
 	"strings"
 )
@@ -13,6 +15,7 @@ import (
 var pySelf = py.Identifier("self")
 
 type Module struct {
+	Header    []py.Stmt
 	Imports   []py.Stmt
 	Values    []py.Stmt
 	Classes   []*py.ClassDef
@@ -21,20 +24,95 @@ type Module struct {
 	Methods   map[py.Identifier][]*py.FunctionDef
 }
 
+// Options control optional translations. The zero value gives the default output.
+type Options struct {
+	// ConditionalExpressions compiles an if/else statement whose branches each
+	// assign to the same variable as a single assignment of a conditional expression.
+	ConditionalExpressions bool
+
+	// UnderscoreUnexported prefixes the names of unexported struct fields and
+	// methods with an underscore, Python's convention for private members.
+	UnderscoreUnexported bool
+
+	// Sets compiles maps with empty struct values, map[K]struct{}, to Python sets.
+	Sets bool
+
+	// GOOS and GOARCH, if not empty, replace runtime.GOOS and runtime.GOARCH
+	// with the target operating system and architecture.
+	GOOS, GOARCH string
+
+	// Enums compiles named integer types whose constants are declared with
+	// iota to Python IntEnum classes, whose members are the constants.
+	Enums bool
+
+	// Modules maps the import paths of Go packages to the Python modules that
+	// they are imported as. Other packages are imported as a module of their
+	// package name.
+	Modules map[string]string
+}
+
 type Compiler struct {
 	*types.Info
 	*scope
 	*token.FileSet
+	Options    Options
 	commentMap *ast.CommentMap
 	defers     py.Expr
+	// deferCalls is the loop that calls the deferred functions of the
+	// function being compiled.
+	deferCalls py.Stmt
+	// results are the named results of the function being compiled if it
+	// defers calls, which a return assigns before the calls.
+	results []*ast.Ident
+
+	// loops are the labels of the statements compiled to the Python loops
+	// enclosing the statement being compiled, innermost last, or nil for
+	// unlabeled statements.
+	loops []*types.Label
+	// labels are the labels of labeled loops.
+	labels map[ast.Stmt]*types.Label
+	// breakFlags are set by breaks to a label from inside nested loops.
+	breakFlags map[*types.Label]*py.Name
+	// continueFlags are set by continues to a label from inside nested loops.
+	continueFlags map[*types.Label]*py.Name
+	// continuePost is the post statement of the innermost for loop, which a
+	// continue runs before the next iteration as Go does.
+	continuePost []py.Stmt
+	// continueFlag is set by a continue inside a switch that compiles to a
+	// loop, to continue the enclosing loop after leaving the switch.
+	continueFlag *py.Name
+	// gotoVar selects the block of the function body that its dispatch loop
+	// runs next, and gotoBlocks are the blocks that start at each label a
+	// goto jumps to.
+	gotoVar    *py.Name
+	gotoBlocks map[string]int
+	// enums are the constants of the types compiled to IntEnum classes, in
+	// the order they are declared.
+	enums map[*types.TypeName][]*types.Const
 }
 
 func NewCompiler(typeInfo *types.Info, fileSet *token.FileSet) *Compiler {
-	return &Compiler{Info: typeInfo, scope: newScope(), FileSet: fileSet}
+	return &Compiler{
+		Info:          typeInfo,
+		scope:         newScope(),
+		FileSet:       fileSet,
+		labels:        map[ast.Stmt]*types.Label{},
+		breakFlags:    map[*types.Label]*py.Name{},
+		continueFlags: map[*types.Label]*py.Name{},
+		enums:         map[*types.TypeName][]*types.Const{},
+	}
 }
 
 func (c Compiler) nestedCompiler() *Compiler {
 	c.scope = c.scope.nested()
+	// Loops do not enclose the statements of function literals
+	c.loops = nil
+	c.continuePost = nil
+	c.continueFlag = nil
+	c.gotoVar = nil
+	c.gotoBlocks = nil
+	c.deferCalls = nil
+	c.results = nil
 	return &c
 }
 
@@ -63,6 +141,17 @@ func (c *Compiler) identifier(ident *ast.Ident) py.Identifier {
 	return c.objID(c.ObjectOf(ident))
 }
 
+// memberID returns the Python identifier of a struct field or method.
+// Members are attributes so their names do not depend on the scope they are used in.
+func (c *Compiler) memberID(obj types.Object) py.Identifier {
+	id := py.Identifier(obj.Name())
+	if c.Options.UnderscoreUnexported && !obj.Exported() {
+		id = "_" + id
+	}
+	c.symbols[obj] = id
+	return id
+}
+
 func (c *Compiler) fieldType(field *ast.Field) py.Identifier {
 	var ident *ast.Ident
 	switch e := field.Type.(type) {
@@ -118,6 +207,9 @@ func (parent *Compiler) compileFunc(name py.Identifier, typ *ast.FuncType, body
 
 	// add an empty list of defer functions before the function body if this function uses defer
 	deferInit := c.addDefers(body)
+	if deferInit != nil {
+		c.results = namedResults(typ)
+	}
 
 	if isMethod {
 		var recvId py.Identifier
@@ -127,37 +219,48 @@ func (parent *Compiler) compileFunc(name py.Identifier, typ *ast.FuncType, body
 			recvId = c.tempID("self")
 		}
 		pyArgs.Args = append(pyArgs.Args, py.Arg{Arg: recvId})
+		if recv != nil && c.copiesReceiver(recv, body) {
+			// A value receiver is the method's own copy of the caller's value
+			self := &py.Name{Id: recvId}
+			pyBody = append(pyBody, &py.Assign{
+				Targets: []py.Expr{self},
+				Value:   c.copyValue(self, c.TypeOf(recv)),
+			})
+		}
 	}
 	for _, param := range typ.Params.List {
 		for _, name := range param.Names {
+			if _, ok := param.Type.(*ast.Ellipsis); ok {
+				// The variadic parameter is a tuple of the remaining arguments,
+				// which is made a list if it is used as more than a tuple
+				pyArgs.Vararg = &py.Arg{Arg: c.identifier(name)}
+				if c.listsVariadic(name, body) {
+					pyBody = append(pyBody, &py.Assign{
+						Targets: []py.Expr{&py.Name{Id: c.identifier(name)}},
+						Value:   &py.Call{Func: pyList, Args: []py.Expr{&py.Name{Id: c.identifier(name)}}},
+					})
+				}
+				continue
+			}
 			pyArgs.Args = append(pyArgs.Args, py.Arg{Arg: c.identifier(name)})
 		}
 	}
 
-	for _, stmt := range body.List {
-		pyBody = append(pyBody, c.compileStmt(stmt)...)
+	// Named results start as their zero values
+	for _, name := range namedResults(typ) {
+		if name.Name != "_" {
+			pyBody = append(pyBody, &py.Assign{
+				Targets: []py.Expr{&py.Name{Id: c.identifier(name)}},
+				Value:   c.zeroValue(c.TypeOf(name)),
+			})
+		}
 	}
 
+	pyBody = append(pyBody, c.compileFuncBody(body)...)
+
 	// Execute defers
 	if deferInit != nil {
-		fun := &py.Name{Id: c.tempID("fun")}
-		args := &py.Name{Id: c.tempID("args")}
-		forLoop := &py.For{
-			Target: makeTuple(fun, args),
-			Iter:   &py.Call{Func: pyReversed, Args: []py.Expr{c.defers}},
-			Body: []py.Stmt{
-				&py.ExprStmt{
-					Value: &py.Call{Func: fun, Args: []py.Expr{&py.Starred{Value: args}}},
-				},
-			},
-		}
-		pyBody = []py.Stmt{
-			deferInit,
-			&py.Try{
-				Body:      pyBody,
-				Finalbody: []py.Stmt{forLoop},
-			},
-		}
+		pyBody = []py.Stmt{deferInit, c.runDefers(typ, pyBody)}
 	}
 
 	if len(pyBody) == 0 {
@@ -166,12 +269,243 @@ func (parent *Compiler) compileFunc(name py.Identifier, typ *ast.FuncType, body
 	return &py.FunctionDef{Name: name, Args: pyArgs, Body: pyBody}
 }
 
+// copiesReceiver reports whether the method with body copies its value
+// receiver recv because it assigns to the receiver's fields or returns it,
+// either of which would otherwise change or alias the caller's struct.
+func (c *Compiler) copiesReceiver(recv *ast.Ident, body *ast.BlockStmt) bool {
+	obj := c.ObjectOf(recv)
+	if obj == nil {
+		return false
+	}
+	if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+		return false
+	}
+	isRecv := func(expr ast.Expr) bool {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		return ok && c.ObjectOf(ident) == obj
+	}
+	// isField reports whether expr is a field of the receiver, or an
+	// element or field of one
+	isField := func(expr ast.Expr) bool {
+		for {
+			switch e := ast.Unparen(expr).(type) {
+			case *ast.SelectorExpr:
+				expr = e.X
+			case *ast.IndexExpr:
+				expr = e.X
+			default:
+				return false
+			}
+			if isRecv(expr) {
+				return true
+			}
+		}
+	}
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				found = found || isField(lhs)
+			}
+		case *ast.IncDecStmt:
+			found = found || isField(n.X)
+		case *ast.ReturnStmt:
+			for _, result := range n.Results {
+				found = found || isRecv(result)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// listsVariadic reports whether the variadic parameter param is used in
+// body as more than a tuple, which is read by index, length or range, or
+// spread in a call other than append.
+func (c *Compiler) listsVariadic(param *ast.Ident, body *ast.BlockStmt) bool {
+	obj := c.ObjectOf(param)
+	if obj == nil || body == nil {
+		return false
+	}
+	isParam := func(expr ast.Expr) bool {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		return ok && c.ObjectOf(ident) == obj
+	}
+	reads := map[ast.Node]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if index, ok := ast.Unparen(lhs).(*ast.IndexExpr); ok && isParam(index.X) {
+					reads[index] = false
+				}
+			}
+		case *ast.IncDecStmt:
+			if index, ok := ast.Unparen(n.X).(*ast.IndexExpr); ok && isParam(index.X) {
+				reads[index] = false
+			}
+		case *ast.IndexExpr:
+			if _, ok := reads[n]; !ok && isParam(n.X) {
+				reads[ast.Unparen(n.X)] = true
+			}
+		case *ast.RangeStmt:
+			if isParam(n.X) {
+				reads[ast.Unparen(n.X)] = true
+			}
+		case *ast.CallExpr:
+			var fun types.Object
+			if ident, ok := ast.Unparen(n.Fun).(*ast.Ident); ok {
+				fun = c.ObjectOf(ident)
+			}
+			switch {
+			case (fun == builtin.len || fun == builtin.cap) && len(n.Args) == 1 && isParam(n.Args[0]):
+				reads[ast.Unparen(n.Args[0])] = true
+			case n.Ellipsis.IsValid() && fun != builtin.append && isParam(n.Args[len(n.Args)-1]):
+				reads[ast.Unparen(n.Args[len(n.Args)-1])] = true
+			}
+		}
+		return true
+	})
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && c.ObjectOf(ident) == obj && !reads[ident] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// copyValue returns a copy of value, a value of type typ. The fields and
+// elements of structs and arrays are copied too, and the other values,
+// such as slices and maps, are shared as they are in Go:
+// T(t.x, Inner(t.inner.y), list(t.arr))
+func (c *Compiler) copyValue(value py.Expr, typ types.Type) py.Expr {
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		named, ok := typ.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || runtimePackages[named.Obj().Pkg().Path()] {
+			return value
+		}
+		var args []py.Expr
+		for _, field := range c.initFields(t) {
+			attr := &py.Attribute{Value: value, Attr: c.memberID(field)}
+			args = append(args, c.copyValue(attr, field.Type()))
+		}
+		return &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}, Args: args}
+	case *types.Array:
+		elem := &py.Name{Id: c.tempID("elem")}
+		elemCopy := c.copyValue(elem, t.Elem())
+		if elemCopy == elem {
+			return &py.Call{Func: pyList, Args: []py.Expr{value}}
+		}
+		return &py.ListComp{
+			Elt:        elemCopy,
+			Generators: []py.Comprehension{{Target: elem, Iter: value}},
+		}
+	}
+	return value
+}
+
+// runDefers wraps body, the body of a function of type typ, in a try
+// statement that calls the deferred functions, last first, when it returns
+// or panics:
+// try: <body>
+// except Exception as panic:
+//
+//	with runtime.asPanic(panic) as panic: <call defers>
+//	if not panic.recovered: raise
+//	return <named results or zero values>
+//
+// finally: <call defers>
+// The defers are called while the panic is active so recover can stop it,
+// and are removed as they are called so they are only called once. An
+// exception that Python raises, such as an IndexError, panics too.
+func (c *Compiler) runDefers(typ *ast.FuncType, body []py.Stmt) py.Stmt {
+	panicking := &py.Name{Id: c.tempID("panic")}
+	recovered := []py.Stmt{
+		&py.With{
+			Items: []py.WithItem{{
+				ContextExpr:  &py.Call{Func: goAsPanic, Args: []py.Expr{panicking}},
+				OptionalVars: panicking,
+			}},
+			Body: []py.Stmt{c.callDefers()},
+		},
+		&py.If{
+			Test: &py.UnaryOpExpr{Op: py.Not, Operand: &py.Attribute{Value: panicking, Attr: pyRecovered}},
+			Body: []py.Stmt{&py.Raise{}},
+		},
+	}
+	if names := namedResults(typ); names != nil {
+		// A function that recovers returns its named results, which the
+		// deferred functions may have set
+		var results []py.Expr
+		for _, name := range names {
+			if name.Name == "_" {
+				results = append(results, c.zeroValue(c.TypeOf(name)))
+			} else {
+				results = append(results, &py.Name{Id: c.identifier(name)})
+			}
+		}
+		recovered = append(recovered, &py.Return{Value: makeTuple(results...)})
+	} else if typ.Results != nil {
+		// A function that recovers returns the zero values of its results
+		var zeros []py.Expr
+		for _, field := range typ.Results.List {
+			zeros = append(zeros, c.zeroValue(c.TypeOf(field.Type)))
+		}
+		recovered = append(recovered, &py.Return{Value: makeTuple(zeros...)})
+	}
+	return &py.Try{
+		Body:      body,
+		Handlers:  []py.ExceptHandler{{Typ: pyException, Name: panicking.Id, Body: recovered}},
+		Finalbody: []py.Stmt{c.callDefers()},
+	}
+}
+
+// callDefers returns a loop that calls the deferred functions, last first:
+// while defers:
+//
+//	fun, args = defers.pop()
+//	fun(*args)
+func (c *Compiler) callDefers() py.Stmt {
+	if c.deferCalls != nil {
+		return c.deferCalls
+	}
+	fun := &py.Name{Id: c.tempID("fun")}
+	args := &py.Name{Id: c.tempID("args")}
+	c.deferCalls = &py.While{
+		Test: c.defers,
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{makeTuple(fun, args)},
+				Value:   &py.Call{Func: &py.Attribute{Value: c.defers, Attr: py.Identifier("pop")}},
+			},
+			&py.ExprStmt{
+				Value: &py.Call{Func: fun, Args: []py.Expr{&py.Starred{Value: args}}},
+			},
+		},
+	}
+	return c.deferCalls
+}
+
 func makeDocString(g *ast.CommentGroup) *py.DocString {
 	text := g.Text()
 	text = strings.TrimRight(text, "\n")
 	return &py.DocString{Lines: strings.Split(text, "\n")}
 }
 
+func makeComments(g *ast.CommentGroup) []py.Stmt {
+	text := g.Text()
+	text = strings.TrimRight(text, "\n")
+	var comments []py.Stmt
+	for _, line := range strings.Split(text, "\n") {
+		comments = append(comments, &py.Comment{Text: " " + line})
+	}
+	return comments
+}
+
 func (c *Compiler) compileFuncDecl(decl *ast.FuncDecl) FuncDecl {
 	var recvType py.Identifier
 	var recv *ast.Ident
@@ -185,7 +519,11 @@ func (c *Compiler) compileFuncDecl(decl *ast.FuncDecl) FuncDecl {
 		}
 		recvType = c.fieldType(field)
 	}
-	funcDef := c.compileFunc(c.identifier(decl.Name), decl.Type, decl.Body, decl.Recv != nil, recv)
+	name := c.identifier(decl.Name)
+	if decl.Recv != nil {
+		name = c.memberID(c.ObjectOf(decl.Name))
+	}
+	funcDef := c.compileFunc(name, decl.Type, decl.Body, decl.Recv != nil, recv)
 
 	if decl.Doc != nil {
 		funcDef.Body = append([]py.Stmt{makeDocString(decl.Doc)}, funcDef.Body...)
@@ -194,7 +532,8 @@ func (c *Compiler) compileFuncDecl(decl *ast.FuncDecl) FuncDecl {
 }
 
 func (c *Compiler) zeroValue(typ types.Type) py.Expr {
-	switch t := typ.(type) {
+	// any is interface{}
+	switch t := types.Unalias(typ).(type) {
 	case *types.Pointer, *types.Slice, *types.Map, *types.Signature, *types.Interface, *types.Chan:
 		return pyNone
 	case *types.Basic:
@@ -207,12 +546,32 @@ func (c *Compiler) zeroValue(typ types.Type) py.Expr {
 			return &py.Num{N: "0"}
 		case t.Info()&types.IsFloat != 0:
 			return &py.Num{N: "0.0"}
+		case t.Info()&types.IsComplex != 0:
+			return &py.Num{N: "0j"}
 		default:
 			panic(fmt.Sprintf("unknown basic type %#v", t))
 		}
 	case *types.Named:
+		switch t.Underlying().(type) {
+		case *types.Signature, *types.Interface, *types.Map:
+			return pyNone
+		}
+		if pkg := t.Obj().Pkg(); pkg != nil && runtimePackages[pkg.Path()] {
+			// sync.WaitGroup is runtime.WaitGroup
+			return &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier(t.Obj().Name())}}
+		}
+		if _, ok := c.enums[t.Obj()]; ok {
+			// An IntEnum is constructed from its value
+			return &py.Call{Func: &py.Name{Id: py.Identifier(t.Obj().Name())}, Args: []py.Expr{&py.Num{N: "0"}}}
+		}
 		return &py.Call{Func: &py.Name{Id: py.Identifier(t.Obj().Name())}}
 	case *types.Array:
+		if t.Len() < 0 {
+			panic(fmt.Sprintf("invalid array length %d", t.Len()))
+		}
+		if t.Len() == 0 {
+			return &py.List{}
+		}
 		return &py.ListComp{
 			Elt: c.zeroValue(t.Elem()),
 			Generators: []py.Comprehension{
@@ -234,25 +593,65 @@ func (c *Compiler) makeInitMethod(typ *types.Struct) *py.FunctionDef {
 	nested := c.nestedCompiler()
 	args := []py.Arg{py.Arg{Arg: pySelf}}
 	var defaults []py.Expr
-	for i := 0; i < typ.NumFields(); i++ {
-		field := typ.Field(i)
-		arg := py.Arg{Arg: nested.objID(field)}
+	var body []py.Stmt
+	params := map[py.Identifier]bool{}
+	for _, field := range c.initFields(typ) {
+		params[c.memberID(field)] = true
+	}
+	for _, field := range c.initFields(typ) {
+		arg := py.Arg{Arg: c.memberID(field)}
 		args = append(args, arg)
 		dflt := nested.zeroValue(field.Type())
+		if isMutableZero(field.Type()) {
+			// A default is evaluated once, so objects shared by every call
+			// are created in the body instead:
+			// if x is None: x = T()
+			name := &py.Name{Id: arg.Arg}
+			body = append(body, &py.If{
+				Test: &py.Compare{Left: name, Ops: []py.CmpOp{py.Is}, Comparators: []py.Expr{pyNone}},
+				Body: []py.Stmt{&py.Assign{Targets: []py.Expr{name}, Value: unshadow(dflt, params)}},
+			})
+			dflt = pyNone
+		}
 		defaults = append(defaults, dflt)
 	}
 
-	var body []py.Stmt
+	inits := len(body)
+	bases := c.baseFields(typ)
 	for i := 0; i < typ.NumFields(); i++ {
 		field := typ.Field(i)
+		if !bases[field] {
+			continue
+		}
+		// The base classes initialize the fields of the embedded structs:
+		// super().__init__(x, y)
+		// C.__init__(self, z)
+		var baseArgs []py.Expr
+		for _, baseField := range c.initFields(structOf(field)) {
+			baseArgs = append(baseArgs, &py.Name{Id: c.memberID(baseField)})
+		}
+		var init py.Expr = &py.Attribute{Value: &py.Call{Func: pySuper}, Attr: py.Identifier("__init__")}
+		if len(body) > inits {
+			// super() is only the first base class
+			base := unshadow(&py.Name{Id: c.objID(embeddedStruct(field).Obj())}, params)
+			init = &py.Attribute{Value: base, Attr: py.Identifier("__init__")}
+			baseArgs = append([]py.Expr{&py.Name{Id: pySelf}}, baseArgs...)
+		}
+		body = append(body, &py.ExprStmt{Value: &py.Call{Func: init, Args: baseArgs}})
+	}
+	for i := 0; i < typ.NumFields(); i++ {
+		field := typ.Field(i)
+		if bases[field] {
+			continue
+		}
 		assign := &py.Assign{
 			Targets: []py.Expr{
 				&py.Attribute{
 					Value: &py.Name{Id: pySelf},
-					Attr:  nested.objID(field),
+					Attr:  c.memberID(field),
 				},
 			},
-			Value: &py.Name{Id: nested.objID(field)},
+			Value: &py.Name{Id: c.memberID(field)},
 		}
 		body = append(body, assign)
 	}
@@ -264,6 +663,206 @@ func (c *Compiler) makeInitMethod(typ *types.Struct) *py.FunctionDef {
 	return initMethod
 }
 
+// unshadow returns expr, the zero value of a field or the name of a class,
+// with the names of classes that params shadow read from the globals of
+// the module: a field inner of type inner is created by
+// globals()["inner"]()
+func unshadow(expr py.Expr, params map[py.Identifier]bool) py.Expr {
+	switch e := expr.(type) {
+	case *py.Name:
+		if params[e.Id] {
+			return &py.Subscript{
+				Value: &py.Call{Func: pyGlobals},
+				Slice: &py.Index{Value: &py.Str{S: strconv.Quote(string(e.Id))}},
+			}
+		}
+	case *py.Call:
+		return &py.Call{Func: unshadow(e.Func, params), Args: e.Args, Keywords: e.Keywords}
+	case *py.ListComp:
+		return &py.ListComp{Elt: unshadow(e.Elt, params), Generators: e.Generators}
+	}
+	return expr
+}
+
+// isMutableZero reports whether the zero value of typ is a mutable object,
+// a struct or an array.
+func isMutableZero(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
+
+// embeddedStruct returns the type of field if it embeds a struct type by
+// value that is declared in the same package, or nil otherwise.
+func embeddedStruct(field *types.Var) *types.Named {
+	if !field.Embedded() {
+		return nil
+	}
+	named, ok := field.Type().(*types.Named)
+	if !ok || named.Obj().Pkg() != field.Pkg() {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil
+	}
+	return named
+}
+
+// structOf returns the struct type of an embedded struct field.
+func structOf(field *types.Var) *types.Struct {
+	return field.Type().Underlying().(*types.Struct)
+}
+
+// baseFields returns the embedded fields of typ whose types are compiled to
+// base classes of its class, so that their fields and methods are
+// inherited. An embedded struct whose fields would share attributes with
+// the other fields stays a field of its own, as embedded pointers do.
+func (c *Compiler) baseFields(typ *types.Struct) map[*types.Var]bool {
+	bases := map[*types.Var]bool{}
+	taken := map[py.Identifier]bool{}
+	for i := 0; i < typ.NumFields(); i++ {
+		if field := typ.Field(i); embeddedStruct(field) == nil {
+			taken[c.memberID(field)] = true
+		}
+	}
+	for i := 0; i < typ.NumFields(); i++ {
+		field := typ.Field(i)
+		if embeddedStruct(field) == nil {
+			continue
+		}
+		attrs := c.initFields(structOf(field))
+		free := true
+		for _, attr := range attrs {
+			free = free && !taken[c.memberID(attr)]
+		}
+		if !free {
+			taken[c.memberID(field)] = true
+			continue
+		}
+		bases[field] = true
+		for _, attr := range attrs {
+			taken[c.memberID(attr)] = true
+		}
+	}
+	return bases
+}
+
+// initFields returns the fields that are the attributes of the objects of
+// the class of typ, in the order of the arguments of its __init__ method.
+// The fields of embedded structs that are base classes take the place of
+// the embedded field.
+func (c *Compiler) initFields(typ *types.Struct) []*types.Var {
+	bases := c.baseFields(typ)
+	var fields []*types.Var
+	for i := 0; i < typ.NumFields(); i++ {
+		field := typ.Field(i)
+		if bases[field] {
+			fields = append(fields, c.initFields(structOf(field))...)
+		} else {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// isMapKey reports whether typ is the key type of a map in the package.
+func (c *Compiler) isMapKey(typ types.Type) bool {
+	for _, tv := range c.Types {
+		if tv.Type == nil {
+			continue
+		}
+		if m, ok := tv.Type.Underlying().(*types.Map); ok && types.Identical(m.Key(), typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// makePromotedMethods returns the methods of the class of named that are
+// promoted from its embedded fields, which delegate to the value of the
+// field, such as an implementation of an embedded interface:
+// def Write(self, *args):
+//
+//	return self.Writer.Write(*args)
+func (c *Compiler) makePromotedMethods(named *types.Named, typ *types.Struct) []py.Stmt {
+	args := py.Identifier("args")
+	var methods []py.Stmt
+	// Python objects are referenced as pointers are, so the methods of *T
+	// are promoted too
+	methodSet := types.NewMethodSet(types.NewPointer(named))
+	for i := 0; i < methodSet.Len(); i++ {
+		sel := methodSet.At(i)
+		if len(sel.Index()) == 1 {
+			// Declared on named itself
+			continue
+		}
+		field := typ.Field(sel.Index()[0])
+		if c.baseFields(typ)[field] {
+			// Inherited from the base class
+			continue
+		}
+		name := c.memberID(sel.Obj())
+		call := &py.Call{
+			Func: &py.Attribute{
+				Value: &py.Attribute{Value: &py.Name{Id: pySelf}, Attr: c.memberID(field)},
+				Attr:  name,
+			},
+			Args: []py.Expr{&py.Starred{Value: &py.Name{Id: args}}},
+		}
+		methods = append(methods, &py.FunctionDef{
+			Name: name,
+			Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}, Vararg: &py.Arg{Arg: args}},
+			Body: []py.Stmt{&py.Return{Value: call}},
+		})
+	}
+	return methods
+}
+
+// makeEqualityMethods returns the __eq__ and __hash__ methods of the class of
+// named, which compare and hash the tuple of the fields of typ.
+func (c *Compiler) makeEqualityMethods(named *types.Named, typ *types.Struct) []py.Stmt {
+	other := py.Identifier("other")
+	var selfFields, otherFields []py.Expr
+	for _, field := range c.initFields(typ) {
+		field := c.memberID(field)
+		selfFields = append(selfFields, &py.Attribute{Value: &py.Name{Id: pySelf}, Attr: field})
+		otherFields = append(otherFields, &py.Attribute{Value: &py.Name{Id: other}, Attr: field})
+	}
+	var equal py.Expr = &py.Call{
+		Func: pyIsInstance,
+		Args: []py.Expr{&py.Name{Id: other}, &py.Name{Id: c.objID(named.Obj())}},
+	}
+	if typ.NumFields() > 0 {
+		equal = &py.BoolOpExpr{
+			Op: py.And,
+			Values: []py.Expr{equal, &py.Compare{
+				Left:        &py.Tuple{Elts: selfFields},
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{&py.Tuple{Elts: otherFields}},
+			}},
+		}
+	}
+	eq := &py.FunctionDef{
+		Name: py.Identifier("__eq__"),
+		Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}, {Arg: other}}},
+		Body: []py.Stmt{&py.Return{Value: equal}},
+	}
+	hash := &py.FunctionDef{
+		Name: py.Identifier("__hash__"),
+		Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}},
+		Body: []py.Stmt{&py.Return{Value: &py.Call{Func: pyHash, Args: []py.Expr{&py.Tuple{Elts: selfFields}}}}},
+	}
+	return []py.Stmt{eq, hash}
+}
+
+// isError reports whether the named type typ, or a pointer to it, implements error.
+func (c *Compiler) isError(typ types.Type) bool {
+	errorType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+	return types.Implements(typ, errorType) || types.Implements(types.NewPointer(typ), errorType)
+}
+
 func (c *Compiler) compileStructType(ident *ast.Ident, typ *types.Struct) *py.ClassDef {
 	var body []py.Stmt
 
@@ -278,23 +877,471 @@ func (c *Compiler) compileStructType(ident *ast.Ident, typ *types.Struct) *py.Cl
 		body = append(body, c.makeInitMethod(typ))
 	}
 
+	// Map keys are hashed and compared by the values of their fields
+	named := c.ObjectOf(ident).Type().(*types.Named)
+	if c.isMapKey(named) {
+		body = append(body, c.makeEqualityMethods(named, typ)...)
+	}
+
+	body = append(body, c.makePromotedMethods(named, typ)...)
+
+	var bases []py.Expr
+	baseFields := c.baseFields(typ)
+	for i := 0; i < typ.NumFields(); i++ {
+		if field := typ.Field(i); baseFields[field] {
+			bases = append(bases, &py.Name{Id: c.objID(embeddedStruct(field).Obj())})
+		}
+	}
+
+	// Errors are exceptions so that Python can raise and catch them
+	if c.isError(c.ObjectOf(ident).Type()) {
+		bases = append(bases, pyException)
+		body = append(body, &py.FunctionDef{
+			Name: py.Identifier("__str__"),
+			Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}},
+			Body: []py.Stmt{&py.Return{Value: &py.Call{
+				Func: &py.Attribute{Value: &py.Name{Id: pySelf}, Attr: py.Identifier("Error")},
+			}}},
+		})
+	}
+
 	if len(body) == 0 {
 		body = []py.Stmt{&py.Pass{}}
 	}
 	return &py.ClassDef{
 		Name:          c.identifier(ident),
-		Bases:         nil,
+		Bases:         bases,
 		Keywords:      nil,
 		Body:          body,
 		DecoratorList: nil,
 	}
 }
 
+// compileMapType compiles a named map type to a class derived from dict, or
+// from set if the map is compiled to a set, which its methods are added to:
+// class Counts(dict):
+func (c *Compiler) compileMapType(ident *ast.Ident, typ *types.Map) *py.ClassDef {
+	var body []py.Stmt
+	if c.commentMap != nil {
+		doc := (*c.commentMap)[ident]
+		if len(doc) > 0 {
+			body = append(body, makeDocString(doc[0]))
+		}
+	}
+	if len(body) == 0 {
+		body = []py.Stmt{&py.Pass{}}
+	}
+	base := pyDict
+	if c.isSet(typ) {
+		base = pySet
+	}
+	return &py.ClassDef{
+		Name:  c.identifier(ident),
+		Bases: []py.Expr{base},
+		Body:  body,
+	}
+}
+
+// compileFuncType compiles a named function type with methods to a class,
+// which its methods are added to, whose instances call the function they
+// are converted from:
+// class HandlerFunc:
+//
+//	def __init__(self, value=None):
+//	    self.value = value
+//
+//	def __call__(self, *args):
+//	    return self.value(*args)
+func (c *Compiler) compileFuncType(ident *ast.Ident) *py.ClassDef {
+	var body []py.Stmt
+	if c.commentMap != nil {
+		doc := (*c.commentMap)[ident]
+		if len(doc) > 0 {
+			body = append(body, makeDocString(doc[0]))
+		}
+	}
+	self := &py.Name{Id: pySelf}
+	value := &py.Name{Id: py.Identifier("value")}
+	args := &py.Name{Id: py.Identifier("args")}
+	body = append(body,
+		&py.FunctionDef{
+			Name: py.Identifier("__init__"),
+			Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}, {Arg: value.Id}}, Defaults: []py.Expr{pyNone}},
+			Body: []py.Stmt{&py.Assign{Targets: []py.Expr{&py.Attribute{Value: self, Attr: value.Id}}, Value: value}},
+		},
+		&py.FunctionDef{
+			Name: py.Identifier("__call__"),
+			Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}, Vararg: &py.Arg{Arg: args.Id}},
+			Body: []py.Stmt{&py.Return{Value: &py.Call{
+				Func: &py.Attribute{Value: self, Attr: value.Id},
+				Args: []py.Expr{&py.Starred{Value: args}},
+			}}},
+		},
+	)
+	return &py.ClassDef{Name: c.identifier(ident), Body: body}
+}
+
+// isFuncClass reports whether typ is a named function type with methods,
+// which is compiled to a class.
+func isFuncClass(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	return ok && isSignature(named.Underlying()) && named.NumMethods() > 0
+}
+
+// compileEnumType compiles a named integer type to an IntEnum class whose
+// members are its constants:
+// class Weekday(runtime.IntEnum):
+//
+//	Sunday = 0
+//	Monday = 1
+func (c *Compiler) compileEnumType(ident *ast.Ident, members []*types.Const) *py.ClassDef {
+	var body []py.Stmt
+	if c.commentMap != nil {
+		doc := (*c.commentMap)[ident]
+		if len(doc) > 0 {
+			body = append(body, makeDocString(doc[0]))
+		}
+	}
+	for _, member := range members {
+		body = append(body, &py.Assign{
+			Targets: []py.Expr{&py.Name{Id: py.Identifier(member.Name())}},
+			Value:   constantLiteral(member.Val()),
+		})
+	}
+	return &py.ClassDef{
+		Name:  c.identifier(ident),
+		Bases: []py.Expr{goIntEnum},
+		Body:  body,
+	}
+}
+
+// findEnums records the constants of the named integer types that are
+// declared in a const group that uses iota, when Options.Enums is set.
+func (c *Compiler) findEnums(files []*ast.File) {
+	if !c.Options.Enums {
+		return
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.CONST || !c.usesIota(genDecl) {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					obj, ok := c.ObjectOf(name).(*types.Const)
+					if !ok || name.Name == "_" {
+						continue
+					}
+					named, ok := obj.Type().(*types.Named)
+					if !ok || named.Obj().Pkg() != obj.Pkg() {
+						continue
+					}
+					if t, ok := named.Underlying().(*types.Basic); !ok || t.Info()&types.IsInteger == 0 {
+						continue
+					}
+					c.enums[named.Obj()] = append(c.enums[named.Obj()], obj)
+				}
+			}
+		}
+	}
+}
+
+// enumMember returns the member of an IntEnum class that the constant obj
+// is, or nil if its type is not compiled to an IntEnum.
+// Blank constants are not members so are their values.
+func (c *Compiler) enumMember(obj *types.Const) py.Expr {
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	if obj.Name() == "_" {
+		if _, ok := c.enums[named.Obj()]; ok {
+			return constantLiteral(obj.Val())
+		}
+		return nil
+	}
+	if _, ok := c.enums[named.Obj()]; !ok {
+		return nil
+	}
+	return &py.Attribute{Value: &py.Name{Id: c.objID(named.Obj())}, Attr: py.Identifier(obj.Name())}
+}
+
+var (
+	abcModule         = &py.Name{Id: py.Identifier("abc")}
+	abcABC            = &py.Attribute{Value: abcModule, Attr: py.Identifier("ABC")}
+	abcAbstractMethod = &py.Attribute{Value: abcModule, Attr: py.Identifier("abstractmethod")}
+)
+
+// compileInterfaceType compiles an interface type to an abstract base class
+// with an abstract method for each method of the interface:
+// class Shape(abc.ABC):
+//
+//	@abc.abstractmethod
+//	def Area(self):
+//	    pass
+//
+// Types that implement the interface do not inherit from the class, but a
+// __subclasshook__ makes isinstance accept them.
 func (c *Compiler) compileInterfaceType(ident *ast.Ident, typ *types.Interface) py.Stmt {
+	var body []py.Stmt
+	if c.commentMap != nil {
+		doc := (*c.commentMap)[ident]
+		if len(doc) > 0 {
+			body = append(body, makeDocString(doc[0]))
+		}
+	}
+	var names []py.Expr
+	for i := 0; i < typ.NumMethods(); i++ {
+		method := typ.Method(i)
+		body = append(body, &py.FunctionDef{
+			Name:          c.memberID(method),
+			Args:          c.abstractMethodArgs(method.Type().(*types.Signature)),
+			Body:          []py.Stmt{&py.Pass{}},
+			DecoratorList: []py.Expr{abcAbstractMethod},
+		})
+		names = append(names, &py.Str{S: strconv.Quote(string(c.memberID(method)))})
+	}
+	if len(names) > 0 {
+		body = append(body, makeSubclassHook(names))
+	}
+	if len(body) == 0 {
+		body = []py.Stmt{&py.Pass{}}
+	}
+	return &py.ClassDef{
+		Name:  c.identifier(ident),
+		Bases: []py.Expr{abcABC},
+		Body:  body,
+	}
+}
+
+// makeSubclassHook returns the __subclasshook__ method of the class of an
+// interface with the methods names, which makes the classes that have the
+// methods subclasses of it, as Go types implement interfaces implicitly:
+// @classmethod
+// def __subclasshook__(cls, C):
+//
+//	return all([callable(getattr(C, name, None)) for name in ("Area",)]) or NotImplemented
+func makeSubclassHook(names []py.Expr) *py.FunctionDef {
+	cls, class, name := py.Identifier("cls"), &py.Name{Id: py.Identifier("C")}, &py.Name{Id: py.Identifier("name")}
+	hasMethod := &py.Call{Func: pyCallable, Args: []py.Expr{
+		&py.Call{Func: pyGetattr, Args: []py.Expr{class, name, pyNone}},
+	}}
+	all := &py.Call{Func: pyAll, Args: []py.Expr{&py.ListComp{
+		Elt:        hasMethod,
+		Generators: []py.Comprehension{{Target: name, Iter: &py.Tuple{Elts: names}}},
+	}}}
+	return &py.FunctionDef{
+		Name:          py.Identifier("__subclasshook__"),
+		Args:          py.Arguments{Args: []py.Arg{{Arg: cls}, {Arg: class.Id}}},
+		Body:          []py.Stmt{&py.Return{Value: &py.BoolOpExpr{Op: py.Or, Values: []py.Expr{all, pyNotImplemented}}}},
+		DecoratorList: []py.Expr{pyClassmethod},
+	}
+}
+
+// abstractMethodArgs returns the arguments of an abstract method with
+// signature sig. Unnamed parameters are given a name.
+func (c *Compiler) abstractMethodArgs(sig *types.Signature) py.Arguments {
+	nested := c.nestedCompiler()
+	args := py.Arguments{Args: []py.Arg{{Arg: nested.tempID(string(pySelf))}}}
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+		var id py.Identifier
+		if param.Name() == "" || param.Name() == "_" {
+			id = nested.tempID("arg")
+		} else {
+			id = nested.objID(param)
+		}
+		if sig.Variadic() && i == params.Len()-1 {
+			args.Vararg = &py.Arg{Arg: id}
+			continue
+		}
+		args.Args = append(args.Args, py.Arg{Arg: id})
+	}
+	return args
+}
+
+// declaresType reports whether files declare a named type whose underlying
+// type is of the same kind as typ. Function types compiled to classes are
+// not counted.
+func (c *Compiler) declaresType(files []*ast.File, typ types.Type) bool {
+	found := false
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok && !spec.Assign.IsValid() {
+				found = reflect.TypeOf(c.TypeOf(spec.Type)) == reflect.TypeOf(typ) &&
+					!isFuncClass(c.ObjectOf(spec.Name).Type())
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+var (
+	typingModule   = &py.Name{Id: py.Identifier("typing")}
+	typingAny      = &py.Attribute{Value: typingModule, Attr: py.Identifier("Any")}
+	typingCallable = &py.Attribute{Value: typingModule, Attr: py.Identifier("Callable")}
+	typingTuple    = &py.Attribute{Value: typingModule, Attr: py.Identifier("Tuple")}
+)
+
+// compileSignatureType compiles a named function type to an alias of the
+// typing.Callable of its signature:
+// Handler = typing.Callable[[int], Exception]
+func (c *Compiler) compileSignatureType(ident *ast.Ident, sig *types.Signature) py.Stmt {
+	return &py.Assign{
+		Targets: []py.Expr{&py.Name{Id: c.identifier(ident)}},
+		Value:   c.annotation(sig),
+	}
+}
+
+// annotation returns the Python type annotation of the values of typ.
+// Types that have no Python class are typing.Any.
+func (c *Compiler) annotation(typ types.Type) py.Expr {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return pyBool
+		case t.Info()&types.IsInteger != 0:
+			return pyInt
+		case t.Info()&types.IsFloat != 0:
+			return pyFloat
+		case t.Info()&types.IsComplex != 0:
+			return pyComplex
+		case t.Info()&types.IsString != 0:
+			return pyStr
+		}
+	case *types.Named:
+		if c.isError(t) {
+			// Errors are exceptions
+			return pyException
+		}
+		pkg := t.Obj().Pkg()
+		if pkg == nil || compiledPackages[pkg.Path()] {
+			return typingAny
+		}
+		if runtimePackages[pkg.Path()] {
+			return &py.Attribute{Value: runtimeModule, Attr: py.Identifier(t.Obj().Name())}
+		}
+		if isSignature(t.Underlying()) {
+			// The alias may be declared after the alias that refers to it,
+			// or be the same alias
+			return &py.Str{S: strconv.Quote(string(c.objID(t.Obj())))}
+		}
+		return &py.Name{Id: c.objID(t.Obj())}
+	case *types.Pointer:
+		return c.annotation(t.Elem())
+	case *types.Slice, *types.Array:
+		return pyList
+	case *types.Map:
+		if c.isSet(t) {
+			return pySet
+		}
+		return pyDict
+	case *types.Signature:
+		var params py.Expr = &py.Ellipsis{}
+		if !t.Variadic() {
+			list := &py.List{Elts: []py.Expr{}}
+			for i := 0; i < t.Params().Len(); i++ {
+				list.Elts = append(list.Elts, c.annotation(t.Params().At(i).Type()))
+			}
+			params = list
+		}
+		var result py.Expr = pyNone
+		switch t.Results().Len() {
+		case 0:
+		case 1:
+			result = c.annotation(t.Results().At(0).Type())
+		default:
+			var elts []py.Expr
+			for i := 0; i < t.Results().Len(); i++ {
+				elts = append(elts, c.annotation(t.Results().At(i).Type()))
+			}
+			result = &py.Subscript{Value: typingTuple, Slice: &py.Index{Value: &py.Tuple{Elts: elts}}}
+		}
+		return &py.Subscript{
+			Value: typingCallable,
+			Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{params, result}}},
+		}
+	}
+	return typingAny
+}
+
+// compileTypeAlias compiles type A = B to an assignment of the Python class of B.
+func (c *Compiler) compileTypeAlias(spec *ast.TypeSpec) py.Stmt {
+	value := c.aliasedClass(c.TypeOf(spec.Type))
+	if value == nil {
+		panic(c.err(spec, "unsupported type alias: %v", c.TypeOf(spec.Type)))
+	}
+	return &py.Assign{
+		Targets: []py.Expr{&py.Name{Id: c.identifier(spec.Name)}},
+		Value:   value,
+	}
+}
+
+// aliasedClass returns the Python class of the values of typ, which a type
+// alias is assigned, or nil if typ has none. Interfaces without a name are
+// typing.Any and function types are the typing.Callable of their signature.
+func (c *Compiler) aliasedClass(typ types.Type) py.Expr {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsString != 0:
+			return pyStr
+		case t.Info()&types.IsBoolean != 0:
+			return pyBool
+		case t.Info()&types.IsInteger != 0:
+			return pyInt
+		case t.Info()&types.IsFloat != 0:
+			return pyFloat
+		case t.Info()&types.IsComplex != 0:
+			return pyComplex
+		}
+	case *types.Named:
+		return &py.Name{Id: c.objID(t.Obj())}
+	case *types.Pointer:
+		// Python objects are referenced as pointers are
+		return c.aliasedClass(t.Elem())
+	case *types.Slice, *types.Array:
+		return pyList
+	case *types.Map:
+		return pyDict
+	case *types.Interface:
+		return typingAny
+	case *types.Signature:
+		return c.annotation(t)
+	}
 	return nil
 }
 
+// aliasesTyping reports whether a type alias declared in files is assigned
+// a member of the typing module.
+func (c *Compiler) aliasesTyping(files []*ast.File) bool {
+	found := false
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok && spec.Assign.IsValid() {
+				typ := types.Unalias(c.TypeOf(spec.Type))
+				for ptr, ok := typ.(*types.Pointer); ok; ptr, ok = typ.(*types.Pointer) {
+					typ = types.Unalias(ptr.Elem())
+				}
+				switch typ.(type) {
+				case *types.Interface, *types.Signature:
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
 func (c *Compiler) compileTypeSpec(spec *ast.TypeSpec) py.Stmt {
+	if spec.Assign.IsValid() {
+		return c.compileTypeAlias(spec)
+	}
 	switch t := c.TypeOf(spec.Type).(type) {
 	case *types.Struct:
 		return c.compileStructType(spec.Name, t)
@@ -305,7 +1352,19 @@ func (c *Compiler) compileTypeSpec(spec *ast.TypeSpec) py.Stmt {
 		}
 	case *types.Interface:
 		return c.compileInterfaceType(spec.Name, t)
+	case *types.Signature:
+		if isFuncClass(c.ObjectOf(spec.Name).Type()) {
+			return c.compileFuncType(spec.Name)
+		}
+		// Functions are assigned and called directly, so the type is only
+		// an alias of typing.Callable
+		return c.compileSignatureType(spec.Name, t)
+	case *types.Map:
+		return c.compileMapType(spec.Name, t)
 	case *types.Basic, *types.Slice:
+		if members, ok := c.enums[c.ObjectOf(spec.Name).(*types.TypeName)]; ok {
+			return c.compileEnumType(spec.Name, members)
+		}
 		fields := []*types.Var{types.NewField(token.NoPos, nil, "value", t, false)}
 		return c.compileStructType(spec.Name, types.NewStruct(fields, nil))
 	default:
@@ -313,8 +1372,52 @@ func (c *Compiler) compileTypeSpec(spec *ast.TypeSpec) py.Stmt {
 	}
 }
 
+// compileImportSpec compiles an import of a package to an import of its
+// Python module, unless the compiler translates the package's members itself.
 func (c *Compiler) compileImportSpec(spec *ast.ImportSpec, module *Module) {
-	//TODO
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		panic(c.err(spec, "bad import path %s", spec.Path.Value))
+	}
+	var pkgName *types.PkgName
+	if spec.Name != nil {
+		pkgName, _ = c.Defs[spec.Name].(*types.PkgName)
+	} else {
+		pkgName, _ = c.Implicits[spec].(*types.PkgName)
+	}
+	name, mapped := c.Options.Modules[importPath]
+	if !mapped {
+		if compiledPackages[importPath] || runtimePackages[importPath] {
+			return
+		}
+		name = path.Base(importPath)
+		if pkgName != nil {
+			name = pkgName.Imported().Name()
+		}
+	}
+	moduleName := py.Identifier(name)
+
+	var stmt py.Stmt
+	switch {
+	case spec.Name != nil && spec.Name.Name == ".":
+		stmt = &py.ImportFrom{Module: &moduleName, Names: []py.Alias{{Name: py.Identifier("*")}}}
+	case spec.Name != nil && spec.Name.Name == "_" || pkgName == nil:
+		// Blank imports are kept for the side effects of importing
+		stmt = &py.Import{Names: []py.Alias{{Name: moduleName}}}
+	default:
+		alias := py.Alias{Name: moduleName}
+		if id := c.objID(pkgName); id != moduleName {
+			alias.Asname = &id
+		}
+		stmt = &py.Import{Names: []py.Alias{alias}}
+	}
+	// Each file of the package imports what it uses
+	for _, imported := range module.Imports {
+		if reflect.DeepEqual(imported, stmt) {
+			return
+		}
+	}
+	module.Imports = append(module.Imports, stmt)
 }
 
 func (c *Compiler) compileGenDecl(decl *ast.GenDecl, module *Module) {
@@ -324,7 +1427,7 @@ func (c *Compiler) compileGenDecl(decl *ast.GenDecl, module *Module) {
 			compiled := c.compileTypeSpec(s)
 			if classDef, ok := compiled.(*py.ClassDef); ok {
 				module.Classes = append(module.Classes, classDef)
-			} else {
+			} else if compiled != nil {
 				module.Types = append(module.Types, compiled)
 			}
 		case *ast.ImportSpec:
@@ -357,26 +1460,105 @@ func (c *Compiler) compileDecl(decl ast.Decl, module *Module) {
 func (c *Compiler) compileFile(file *ast.File, module *Module) {
 	cmap := ast.NewCommentMap(c.FileSet, file, file.Comments)
 	c1 := c.withCommentMap(&cmap)
+	if module.Header == nil {
+		module.Header = fileHeader(file)
+	}
 	for _, decl := range file.Decls {
 		c1.compileDecl(decl, module)
 	}
 }
 
+// fileHeader returns the comments before the package clause of file, such as
+// a license, which head the module. The package doc and build constraints
+// are left out.
+func fileHeader(file *ast.File) []py.Stmt {
+	var header []py.Stmt
+	for _, commentGroup := range file.Comments {
+		if commentGroup.Pos() >= file.Package || commentGroup == file.Doc || commentGroup.Text() == "" {
+			continue
+		}
+		header = append(header, makeComments(commentGroup)...)
+	}
+	return header
+}
+
 func (c *Compiler) CompileFiles(files []*ast.File) *py.Module {
 	module := &Module{Methods: map[py.Identifier][]*py.FunctionDef{}}
+	c.findEnums(files)
+	c.checkRuntimeMembers(files)
+	if c.declaresType(files, &types.Interface{}) {
+		module.Imports = append(module.Imports, &py.Import{
+			Names: []py.Alias{{Name: abcModule.Id}},
+		})
+	}
+	if c.declaresType(files, &types.Signature{}) || c.aliasesTyping(files) {
+		module.Imports = append(module.Imports, &py.Import{
+			Names: []py.Alias{{Name: typingModule.Id}},
+		})
+	}
+	if c.usesRuntime(files) {
+		module.Imports = append(module.Imports, &py.Import{
+			Names: []py.Alias{{Name: runtimeModule.Id}},
+		})
+	}
 	for _, file := range files {
 		c.compileFile(file, module)
 	}
 	pyModule := &py.Module{}
-	pyModule.Body = append(pyModule.Body, module.Values...)
-	for _, class := range module.Classes {
+	pyModule.Body = append(pyModule.Body, module.Header...)
+	pyModule.Body = append(pyModule.Body, module.Imports...)
+	for _, class := range orderClasses(module.Classes) {
 		for _, method := range module.Methods[class.Name] {
 			class.Body = append(class.Body, method)
 		}
 		pyModule.Body = append(pyModule.Body, class)
 	}
+	pyModule.Body = append(pyModule.Body, module.Types...)
+	// Values come after the classes and types that they may be made of
+	pyModule.Body = append(pyModule.Body, module.Values...)
 	for _, fun := range module.Functions {
 		pyModule.Body = append(pyModule.Body, fun)
 	}
 	return pyModule
 }
+
+// orderClasses returns classes in the order they are declared, except that
+// a class comes after the classes of the module that it derives from.
+func orderClasses(classes []*py.ClassDef) []*py.ClassDef {
+	byName := map[py.Identifier]*py.ClassDef{}
+	for _, class := range classes {
+		byName[class.Name] = class
+	}
+	var ordered []*py.ClassDef
+	added := map[*py.ClassDef]bool{}
+	var add func(class *py.ClassDef)
+	add = func(class *py.ClassDef) {
+		if added[class] {
+			return
+		}
+		added[class] = true
+		for _, base := range class.Bases {
+			if name, ok := base.(*py.Name); ok && byName[name.Id] != nil {
+				add(byName[name.Id])
+			}
+		}
+		ordered = append(ordered, class)
+	}
+	for _, class := range classes {
+		add(class)
+	}
+	return ordered
+}
+
+// namedResults returns the names of the results of a function, or nil if
+// they are not named.
+func namedResults(typ *ast.FuncType) []*ast.Ident {
+	if typ.Results == nil {
+		return nil
+	}
+	var names []*ast.Ident
+	for _, field := range typ.Results.List {
+		names = append(names, field.Names...)
+	}
+	return names
+}
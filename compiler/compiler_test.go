@@ -0,0 +1,228 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderComment(t *testing.T) {
+	const a = `// Copyright 2017 The Authors.
+// Use of this source code is governed by a license.
+
+//go:build linux
+
+// Package main is documented after the license.
+package main
+
+// F is not the header.
+func F() {}
+`
+	const b = `// Copyright 2017 The Authors.
+// Use of this source code is governed by a license.
+
+package main
+`
+	fset := token.NewFileSet()
+	pkg, errs := buildPackage(fset, a, b)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, fset).CompileFiles(pkg.Files)
+	// The header is taken from one file only
+	want := []py.Stmt{
+		&py.Comment{Text: " Copyright 2017 The Authors."},
+		&py.Comment{Text: " Use of this source code is governed by a license."},
+	}
+	// The comment on F stays with F
+	if len(module.Body) != len(want)+1 || !reflect.DeepEqual(module.Body[:len(want)], want) {
+		t.Errorf("want header:\n%s\ngot:\n%s", pythonCode(want), pythonCode(module.Body))
+	}
+}
+
+func TestTrailingComment(t *testing.T) {
+	const golang = `package main
+
+func F() int {
+	// x is one
+	x := 1 // a trailing comment
+	return x
+}
+`
+	fset := token.NewFileSet()
+	pkg, file, errs := buildFileSet(fset, golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, fset).CompileFiles([]*ast.File{file})
+	want := []py.Stmt{
+		&py.Comment{Text: " x is one"},
+		&py.Assign{Targets: []py.Expr{x}, Value: one},
+		&py.Comment{Text: " a trailing comment"},
+		&py.Return{Value: x},
+	}
+	got := module.Body[0].(*py.FunctionDef).Body
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%s\ngot:\n%s", pythonCode(want), pythonCode(got))
+	}
+}
+
+func TestImports(t *testing.T) {
+	const golang = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	m "math"
+	_ "os"
+	. "sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var _, _, _ = unicode.ToUpper, m.Abs, json.Marshal
+var _, _, _ = fmt.Println, strconv.FormatInt(1, 10), strings.ToUpper("a")
+var _ = Ints
+`
+	pkg, file, errs := buildFile(golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	c := NewCompiler(&pkg.Info, nil)
+	c.Options.Modules = map[string]string{"encoding/json": "jsonlib", "fmt": "gofmt"}
+	module := c.CompileFiles([]*ast.File{file})
+	alias := func(name string) *py.Identifier {
+		id := py.Identifier(name)
+		return &id
+	}
+	want := []py.Stmt{
+		&py.Import{Names: []py.Alias{{Name: py.Identifier("jsonlib"), Asname: alias("json")}}},
+		&py.Import{Names: []py.Alias{{Name: py.Identifier("gofmt"), Asname: alias("fmt")}}},
+		&py.Import{Names: []py.Alias{{Name: py.Identifier("math"), Asname: alias("m")}}},
+		&py.Import{Names: []py.Alias{{Name: py.Identifier("os")}}},
+		&py.ImportFrom{Module: alias("sort"), Names: []py.Alias{{Name: py.Identifier("*")}}},
+		&py.Import{Names: []py.Alias{{Name: py.Identifier("unicode")}}},
+	}
+	if len(module.Body) < len(want) || !reflect.DeepEqual(module.Body[:len(want)], want) {
+		t.Errorf("want imports:\n%s\ngot:\n%s", pythonCode(want), pythonCode(module.Body))
+	}
+}
+
+// Modules that declare an interface import abc for its base class
+func TestInterfaceImport(t *testing.T) {
+	const golang = `package main
+
+import "strconv"
+
+type Stringer interface{ String() string }
+
+var _ = strconv.FormatInt(1, 10)
+`
+	pkg, file, errs := buildFile(golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, nil).CompileFiles([]*ast.File{file})
+	want := &py.Import{Names: []py.Alias{{Name: py.Identifier("abc")}}}
+	if len(module.Body) == 0 || !reflect.DeepEqual(module.Body[0], want) {
+		t.Errorf("want import abc, got:\n%s", pythonCode(module.Body))
+	}
+}
+
+// A function type with methods is a class that its methods are declared in,
+// whose instances call the function they hold
+func TestFuncTypeMethod(t *testing.T) {
+	const golang = `package main
+
+type F func(int) int
+
+func (f F) twice(n int) int { return f(f(n)) }
+`
+	pkg, file, errs := buildFile(golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, nil).CompileFiles([]*ast.File{file})
+	f := &py.Name{Id: py.Identifier("f")}
+	n := &py.Name{Id: py.Identifier("n")}
+	value := &py.Name{Id: py.Identifier("value")}
+	args := &py.Name{Id: py.Identifier("args")}
+	want := &py.ClassDef{
+		Name: py.Identifier("F"),
+		Body: []py.Stmt{
+			&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}, {Arg: value.Id}}, Defaults: []py.Expr{pyNone}},
+				Body: []py.Stmt{&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: value.Id}}, Value: value}},
+			},
+			&py.FunctionDef{
+				Name: py.Identifier("__call__"),
+				Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}, Vararg: &py.Arg{Arg: args.Id}},
+				Body: []py.Stmt{&py.Return{Value: &py.Call{
+					Func: &py.Attribute{Value: &py.Name{Id: pySelf}, Attr: value.Id},
+					Args: []py.Expr{&py.Starred{Value: args}},
+				}}},
+			},
+			&py.FunctionDef{
+				Name: py.Identifier("twice"),
+				Args: py.Arguments{Args: []py.Arg{{Arg: f.Id}, {Arg: n.Id}}},
+				Body: []py.Stmt{&py.Return{Value: &py.Call{Func: f, Args: []py.Expr{&py.Call{Func: f, Args: []py.Expr{n}}}}}},
+			},
+		},
+	}
+	if len(module.Body) == 0 || !reflect.DeepEqual(module.Body[len(module.Body)-1], want) {
+		t.Errorf("want:\n%s\ngot:\n%s", pythonCode([]py.Stmt{want}), pythonCode(module.Body))
+	}
+}
+
+// The runtime module implements only some members of runtime and sync
+func TestUnsupportedRuntimeMember(t *testing.T) {
+	tests := []struct {
+		golang string
+		want   string
+	}{
+		{"package main\nimport \"runtime\"\nvar n = runtime.NumCPU()", "runtime.NumCPU is not supported"},
+		{"package main\nimport \"sync\"\nvar mu sync.RWMutex", "sync.RWMutex is not supported"},
+		{"package main\nimport \"sync\"\ntype T struct{ once sync.Once }", "sync.Once is not supported"},
+		// Nor are the members of fmt, strings and strconv without a translation
+		{"package main\nimport \"fmt\"\nfunc f() error { return fmt.Errorf(\"x\") }", "fmt.Errorf is not supported"},
+		{"package main\nimport \"strings\"\nfunc f() string { return strings.Title(\"x\") }", "strings.Title is not supported"},
+		{"package main\nimport \"strings\"\nvar upper = strings.ToUpper", "strings.ToUpper is not supported"},
+		{"package main\nimport \"strconv\"\nfunc f() string { return strconv.Itoa(1) }", "strconv.Itoa is not supported"},
+	}
+	for _, test := range tests {
+		pkg, file, errs := buildFile(test.golang)
+		if errs != nil {
+			t.Fatal(errs)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != test.want {
+					t.Errorf("%s: want panic %q, got %v", test.golang, test.want, r)
+				}
+			}()
+			NewCompiler(&pkg.Info, nil).CompileFiles([]*ast.File{file})
+		}()
+	}
+}
+
+func TestTypingImport(t *testing.T) {
+	const golang = `package main
+
+type Handler func(int) error
+
+type Server struct{ handle Handler }
+`
+	pkg, file, errs := buildFile(golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, nil).CompileFiles([]*ast.File{file})
+	want := &py.Import{Names: []py.Alias{{Name: py.Identifier("typing")}}}
+	if len(module.Body) == 0 || !reflect.DeepEqual(module.Body[0], want) {
+		t.Errorf("want import typing, got:\n%s", pythonCode(module.Body))
+	}
+}
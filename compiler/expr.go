@@ -4,8 +4,10 @@ import (
 	"fmt"
 	py "github.com/mbergin/gotopython/pythonast"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
+	"strconv"
 	"strings"
 )
 
@@ -89,12 +91,146 @@ func boolOp(t token.Token) (py.BoolOp, bool) {
 	return py.BoolOp(0), false
 }
 
+// isWrapper reports whether values of typ are instances of a class wrapping
+// the underlying value in its value attribute. See compileTypeSpec.
+func isWrapper(typ types.Type) bool {
+	if _, ok := typ.(*types.Named); !ok {
+		return false
+	}
+	switch typ.Underlying().(type) {
+	case *types.Basic, *types.Slice:
+		return true
+	}
+	return false
+}
+
+// isSet reports whether values of typ are compiled to Python sets.
+func (c *Compiler) isSet(typ types.Type) bool {
+	if !c.Options.Sets {
+		return false
+	}
+	m, ok := typ.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	elem, ok := m.Elem().Underlying().(*types.Struct)
+	return ok && elem.NumFields() == 0
+}
+
+// compileSetContains compiles the membership test of the key of the set read s[k].
+func (c *exprCompiler) compileSetContains(expr *ast.IndexExpr) py.Expr {
+	return &py.Compare{
+		Left:        c.compileExpr(expr.Index),
+		Ops:         []py.CmpOp{py.In},
+		Comparators: []py.Expr{orEmptyMap(c.compileExpr(expr.X))},
+	}
+}
+
+// compileUnwrapped compiles expr to its underlying Python value if it has a wrapper type.
+func (c *exprCompiler) compileUnwrapped(expr ast.Expr) py.Expr {
+	if !isWrapper(c.TypeOf(expr)) {
+		return c.compileExpr(expr)
+	}
+	if value := c.Types[expr].Value; value != nil {
+		return constantLiteral(value)
+	}
+	return &py.Attribute{Value: c.compileExpr(expr), Attr: py.Identifier("value")}
+}
+
+// compileWrappedConstant compiles a constant expression of a wrapper type to
+// a construction of the wrapper from the constant's literal value.
+// Named constants are already wrapped when they are declared.
+// It returns nil if expr is not such an expression.
+func (c *exprCompiler) compileWrappedConstant(expr ast.Expr) py.Expr {
+	tv := c.Types[expr]
+	if tv.Value == nil || !isWrapper(tv.Type) {
+		return nil
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		if _, ok := c.ObjectOf(ident).(*types.Const); ok {
+			return nil
+		}
+	}
+	return c.compileConstant(tv.Type, tv.Value)
+}
+
+// compileConstant compiles a constant value of type typ, constructing the
+// wrapper from the literal if typ is a wrapper type.
+func (c *Compiler) compileConstant(typ types.Type, value constant.Value) py.Expr {
+	if !isWrapper(typ) {
+		return constantLiteral(value)
+	}
+	named := typ.(*types.Named)
+	return &py.Call{
+		Func: &py.Name{Id: c.objID(named.Obj())},
+		Args: []py.Expr{constantLiteral(value)},
+	}
+}
+
+// usesIota reports whether expr, or the declaration, refers to iota.
+func (c *Compiler) usesIota(expr ast.Node) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && c.ObjectOf(ident) == types.Universe.Lookup("iota") {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// constantLiteral returns the Python literal of a constant value.
+func constantLiteral(value constant.Value) py.Expr {
+	switch value.Kind() {
+	case constant.Bool:
+		if constant.BoolVal(value) {
+			return pyTrue
+		}
+		return pyFalse
+	case constant.String:
+		return &py.Str{S: strconv.Quote(constant.StringVal(value))}
+	case constant.Int:
+		return &py.Num{N: value.ExactString()}
+	case constant.Float:
+		f, _ := constant.Float64Val(value)
+		n := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(n, ".e") {
+			n += ".0"
+		}
+		return &py.Num{N: n}
+	case constant.Complex:
+		return &py.Call{
+			Func: pyComplex,
+			Args: []py.Expr{constantLiteral(constant.Real(value)), constantLiteral(constant.Imag(value))},
+		}
+	}
+	panic(fmt.Sprintf("unknown constant: %v", value))
+}
+
+// isShift reports whether expr contains a shift.
+func isShift(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if binary, ok := node.(*ast.BinaryExpr); ok && (binary.Op == token.SHL || binary.Op == token.SHR) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
 func (c *exprCompiler) compileBinaryExpr(expr *ast.BinaryExpr) py.Expr {
+	// A constant shift is folded, as its left operand may be an untyped
+	// float constant such as 1.0 that Python cannot shift, and so is a
+	// constant string concatenation into a single literal
+	if tv := c.Types[expr]; tv.Value != nil && (isShift(expr) || tv.Value.Kind() == constant.String) {
+		return c.compileConstant(tv.Type, tv.Value)
+	}
 	if pyCmp, ok := comparator(expr.Op); ok {
 		return &py.Compare{
-			Left:        c.compileExpr(expr.X),
+			Left:        c.compileUnwrapped(expr.X),
 			Ops:         []py.CmpOp{pyCmp},
-			Comparators: []py.Expr{c.compileExpr(expr.Y)}}
+			Comparators: []py.Expr{c.compileUnwrapped(expr.Y)}}
 	}
 	if pyOp, ok := binOp(expr.Op); ok {
 		return &py.BinOp{Left: c.compileExpr(expr.X),
@@ -116,14 +252,28 @@ func (c *exprCompiler) compileBinaryExpr(expr *ast.BinaryExpr) py.Expr {
 
 func (c *exprCompiler) compileBasicLit(expr *ast.BasicLit) py.Expr {
 	switch expr.Kind {
-	case token.INT, token.FLOAT:
-		return &py.Num{N: expr.Value}
+	case token.INT:
+		// Python does not allow an underscore after the base prefix, so remove them all
+		value := strings.Replace(expr.Value, "_", "", -1)
+		if len(value) > 1 && value[0] == '0' && value[1] >= '0' && value[1] <= '9' {
+			// Legacy octal literal 017 is 0o17 in Python
+			value = "0o" + value[1:]
+		}
+		return &py.Num{N: value}
+	case token.FLOAT:
+		return &py.Num{N: strings.Replace(expr.Value, "_", "", -1)}
 	case token.CHAR:
-		return &py.Str{S: expr.Value}
+		// A rune is the integer value of its code point
+		r, _, _, err := strconv.UnquoteChar(expr.Value[1:len(expr.Value)-1], '\'')
+		if err != nil {
+			panic(c.err(expr, "bad rune literal %s: %v", expr.Value, err))
+		}
+		return &py.Num{N: strconv.Itoa(int(r))}
 	case token.STRING:
 		return &py.Str{S: expr.Value}
 	case token.IMAG:
-		return &py.Num{N: strings.Replace(expr.Value, "i", "j", 1)}
+		value := strings.Replace(expr.Value, "_", "", -1)
+		return &py.Num{N: strings.Replace(value, "i", "j", 1)}
 	}
 	panic(c.err(expr, "unknown BasicLit kind: %v", expr.Kind))
 }
@@ -133,27 +283,54 @@ func (c *exprCompiler) compileUnaryExpr(expr *ast.UnaryExpr) py.Expr {
 	case token.NOT:
 		return &py.UnaryOpExpr{Op: py.Not, Operand: c.compileExpr(expr.X)}
 	case token.AND: // address of
-		return c.compileExpr(expr.X)
+		return c.compileEmbedded(expr.X)
 	case token.ADD:
 		return &py.UnaryOpExpr{Op: py.UAdd, Operand: c.compileExpr(expr.X)}
 	case token.SUB:
 		return &py.UnaryOpExpr{Op: py.USub, Operand: c.compileExpr(expr.X)}
 	case token.XOR:
 		return &py.UnaryOpExpr{Op: py.Invert, Operand: c.compileExpr(expr.X)}
+	case token.ARROW:
+		// Comma-ok form: v, ok := <-ch becomes v, ok = ch.recvOk()
+		recv := py.Identifier("recv")
+		if _, ok := c.TypeOf(expr).(*types.Tuple); ok {
+			recv = py.Identifier("recvOk")
+		}
+		return &py.Call{Func: &py.Attribute{Value: c.compileExpr(expr.X), Attr: recv}}
 	}
 	panic(c.err(expr, "unknown UnaryExpr: %v", expr.Op))
 }
 
 func (c *exprCompiler) compileCompositeLit(expr *ast.CompositeLit) py.Expr {
-	switch typ := c.TypeOf(expr).(type) {
+	litType := c.TypeOf(expr)
+	if ptr, ok := litType.(*types.Pointer); ok {
+		// An element literal that elides &T is a pointer, which is the
+		// object of T
+		litType = ptr.Elem()
+	}
+	switch typ := litType.(type) {
 	case *types.Named:
+		if m, ok := typ.Underlying().(*types.Map); ok {
+			// The class of a named map type derives from dict
+			var args []py.Expr
+			if len(expr.Elts) > 0 {
+				args = []py.Expr{c.compileMapLit(expr, m)}
+			}
+			return &py.Call{Func: &py.Name{Id: c.objID(typ.Obj())}, Args: args}
+		}
+		if st, ok := typ.Underlying().(*types.Struct); ok && len(c.baseFields(st)) > 0 {
+			return &py.Call{
+				Func:     &py.Name{Id: c.objID(typ.Obj())},
+				Keywords: c.compileStructLitKeywords(expr, st),
+			}
+		}
 		var args []py.Expr
 		var keywords []py.Keyword
 		if len(expr.Elts) > 0 {
 			if _, ok := expr.Elts[0].(*ast.KeyValueExpr); ok {
 				for _, elt := range expr.Elts {
 					kv := elt.(*ast.KeyValueExpr)
-					id := c.identifier(kv.Key.(*ast.Ident))
+					id := c.memberID(c.ObjectOf(kv.Key.(*ast.Ident)))
 					keyword := py.Keyword{
 						Arg:   &id,
 						Value: c.compileExpr(kv.Value)}
@@ -184,24 +361,193 @@ func (c *exprCompiler) compileCompositeLit(expr *ast.CompositeLit) py.Expr {
 		}
 		return &py.List{Elts: elts}
 	case *types.Map:
-		keys := make([]py.Expr, len(expr.Elts))
-		values := make([]py.Expr, len(expr.Elts))
-		for i, elt := range expr.Elts {
-			kv := elt.(*ast.KeyValueExpr)
-			keys[i] = c.compileExpr(kv.Key)
-			values[i] = c.compileExpr(kv.Value)
-		}
-		return &py.Dict{Keys: keys, Values: values}
+		return c.compileMapLit(expr, typ)
 	default:
 		panic(c.err(expr, "Unknown composite literal type: %T", typ))
 	}
 }
 
+// compileStructLitKeywords compiles the elements of a composite literal of
+// struct type typ to the keyword arguments of its class. The fields of an
+// embedded struct that is a base class are arguments of their own:
+// B{A: A{x: 1}, y: 2} becomes B(x=1, y=2)
+// B{A: a, y: 2} becomes B(x=a.x, y=2)
+// B{A: f(), y: 2} becomes B(**vars(f()), y=2)
+func (c *exprCompiler) compileStructLitKeywords(expr *ast.CompositeLit, typ *types.Struct) []py.Keyword {
+	bases := c.baseFields(typ)
+	var keywords []py.Keyword
+	for i, elt := range expr.Elts {
+		field := typ.Field(i)
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			field = c.ObjectOf(kv.Key.(*ast.Ident)).(*types.Var)
+			elt = kv.Value
+		}
+		if !bases[field] {
+			id := c.memberID(field)
+			keywords = append(keywords, py.Keyword{Arg: &id, Value: c.compileExpr(elt)})
+			continue
+		}
+		if lit, ok := ast.Unparen(elt).(*ast.CompositeLit); ok {
+			keywords = append(keywords, c.compileStructLitKeywords(lit, structOf(field))...)
+			continue
+		}
+		value := c.compileEmbedded(elt)
+		if !isAttributePath(value) {
+			// A computed value, such as a call, is evaluated where the
+			// literal is, which may be in a condition that short-circuits
+			keywords = append(keywords, py.Keyword{Value: &py.Call{Func: pyVars, Args: []py.Expr{value}}})
+			continue
+		}
+		for _, attr := range c.initFields(structOf(field)) {
+			id := c.memberID(attr)
+			keywords = append(keywords, py.Keyword{Arg: &id, Value: &py.Attribute{Value: value, Attr: id}})
+		}
+	}
+	return keywords
+}
+
+// compileMapLit compiles a composite literal of map type typ to a dict, or
+// to a set if typ is compiled to sets.
+func (c *exprCompiler) compileMapLit(expr *ast.CompositeLit, typ *types.Map) py.Expr {
+	if c.isSet(typ) {
+		set := &py.Set{}
+		for _, elt := range expr.Elts {
+			set.Elts = append(set.Elts, c.compileExpr(elt.(*ast.KeyValueExpr).Key))
+		}
+		return set
+	}
+	keys := make([]py.Expr, len(expr.Elts))
+	values := make([]py.Expr, len(expr.Elts))
+	for i, elt := range expr.Elts {
+		kv := elt.(*ast.KeyValueExpr)
+		keys[i] = c.compileExpr(kv.Key)
+		values[i] = c.compileExpr(kv.Value)
+	}
+	return &py.Dict{Keys: keys, Values: values}
+}
+
 func (c *exprCompiler) compileSelectorExpr(expr *ast.SelectorExpr) py.Expr {
-	return &py.Attribute{
-		Value: c.compileExpr(expr.X),
-		Attr:  c.identifier(expr.Sel),
+	value := c.compileSelection(expr)
+	if base := c.baseSelection(expr); base != nil {
+		// The fields of an inherited struct are attributes of the outer
+		// object, so its value is a new object made of them: A(b.x, b.y)
+		return c.copyEmbedded(value, base)
 	}
+	return value
+}
+
+// compileEmbedded compiles expr, whose field or method is selected or
+// whose address is taken. An inherited struct is the outer object.
+func (c *exprCompiler) compileEmbedded(expr ast.Expr) py.Expr {
+	if selector, ok := ast.Unparen(expr).(*ast.SelectorExpr); ok && c.baseSelection(selector) != nil {
+		return c.compileSelection(selector)
+	}
+	return c.compileExpr(expr)
+}
+
+// copyEmbedded returns a copy of the inherited struct of type typ whose
+// fields are attributes of value. A value that is not a name or attribute
+// is evaluated once, where it is: (lambda value: A(value.x))(f())
+func (c *exprCompiler) copyEmbedded(value py.Expr, typ *types.Named) py.Expr {
+	if isAttributePath(value) {
+		return c.copyValue(value, typ)
+	}
+	param := &py.Name{Id: c.tempID("value")}
+	return &py.Call{
+		Func: &py.Lambda{Args: py.Arguments{Args: []py.Arg{{Arg: param.Id}}}, Body: c.copyValue(param, typ)},
+		Args: []py.Expr{value},
+	}
+}
+
+// isAttributePath reports whether value is a name or an attribute of one,
+// which can be evaluated again without effects.
+func isAttributePath(value py.Expr) bool {
+	for {
+		switch v := value.(type) {
+		case *py.Name:
+			return true
+		case *py.Attribute:
+			value = v.Value
+		default:
+			return false
+		}
+	}
+}
+
+// compileSelection compiles the selector expr to the attribute it selects.
+func (c *exprCompiler) compileSelection(expr *ast.SelectorExpr) py.Expr {
+	if pkg := c.importedPackage(expr.X); pkg != nil {
+		if compiled := c.compilePackageSelector(pkg.Path(), expr.Sel.Name); compiled != nil {
+			return compiled
+		}
+		// Only calls to the members of fmt, strings and strconv are translated
+		c.checkTranslated(expr, pkg.Path(), expr.Sel.Name)
+	}
+	if c.isRuntimePackage(expr.X) {
+		return &py.Attribute{Value: runtimeModule, Attr: py.Identifier(expr.Sel.Name)}
+	}
+	sel, ok := c.Selections[expr]
+	if !ok {
+		return &py.Attribute{Value: c.compileExpr(expr.X), Attr: c.identifier(expr.Sel)}
+	}
+	value := c.compileEmbedded(expr.X)
+	if base := c.baseSelection(expr.X); base != nil && sel.Kind() == types.MethodVal {
+		// The method of the embedded struct, even if the outer struct
+		// overrides it: A.M.__get__(b)
+		method := &py.Attribute{Value: &py.Name{Id: c.objID(base.Obj())}, Attr: c.memberID(sel.Obj())}
+		return &py.Call{Func: &py.Attribute{Value: method, Attr: py.Identifier("__get__")}, Args: []py.Expr{value}}
+	}
+	if sel.Kind() == types.FieldVal {
+		// The path to a promoted field only includes the embedded fields
+		// that are not inherited
+		typ := sel.Recv()
+		for _, index := range sel.Index() {
+			st, ok := derefStruct(typ)
+			if !ok {
+				break
+			}
+			field := st.Field(index)
+			if !c.baseFields(st)[field] {
+				value = &py.Attribute{Value: value, Attr: c.memberID(field)}
+			}
+			typ = field.Type()
+		}
+		return value
+	}
+	return &py.Attribute{Value: value, Attr: c.memberID(sel.Obj())}
+}
+
+// baseSelection returns the type of the embedded struct that expr selects
+// if it is inherited by the class of the outer struct, or nil otherwise.
+func (c *Compiler) baseSelection(expr ast.Expr) *types.Named {
+	selector, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	sel, ok := c.Selections[selector]
+	if !ok || sel.Kind() != types.FieldVal {
+		return nil
+	}
+	field := sel.Obj().(*types.Var)
+	parent := sel.Recv()
+	for _, index := range sel.Index()[:len(sel.Index())-1] {
+		st, _ := derefStruct(parent)
+		parent = st.Field(index).Type()
+	}
+	st, ok := derefStruct(parent)
+	if !ok || !c.baseFields(st)[field] {
+		return nil
+	}
+	return embeddedStruct(field)
+}
+
+// derefStruct returns the struct type of typ or of the type it points to.
+func derefStruct(typ types.Type) (*types.Struct, bool) {
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	return st, ok
 }
 
 func isString(typ types.Type) bool {
@@ -209,6 +555,16 @@ func isString(typ types.Type) bool {
 	return ok && t.Info()&types.IsString != 0
 }
 
+func isInterface(typ types.Type) bool {
+	_, ok := typ.Underlying().(*types.Interface)
+	return ok
+}
+
+func isMap(typ types.Type) bool {
+	_, ok := typ.Underlying().(*types.Map)
+	return ok
+}
+
 var builtin = struct {
 	append  types.Object
 	cap     types.Object
@@ -249,16 +605,148 @@ var builtin = struct {
 	nil:     types.Universe.Lookup("nil"),
 }
 
+// compileConversion compiles a conversion expression T(x).
+// It returns nil if the conversion should be compiled as a call.
+func (c *exprCompiler) compileConversion(expr *ast.CallExpr) py.Expr {
+	if value := c.Types[expr].Value; value != nil {
+		return constantLiteral(value)
+	}
+	typ := c.TypeOf(expr.Fun)
+	arg := expr.Args[0]
+	switch t := typ.Underlying().(type) {
+	case *types.Signature, *types.Interface, *types.Pointer, *types.Map, *types.Chan:
+		if isFuncClass(typ) {
+			// The class of the function type calls the function
+			named := typ.(*types.Named)
+			return &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}, Args: []py.Expr{c.compileExpr(arg)}}
+		}
+		// These are the same Python objects after conversion
+		return c.compileExpr(arg)
+	case *types.Basic, *types.Slice:
+		value := convertValue(t, c.TypeOf(arg).Underlying(), c.compileUnwrapped(arg))
+		if isWrapper(typ) {
+			named := typ.(*types.Named)
+			return &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}, Args: []py.Expr{value}}
+		}
+		return value
+	case *types.Struct:
+		named, ok := typ.(*types.Named)
+		if !ok {
+			return c.compileExpr(arg)
+		}
+		// The fields have identical types, so T2(t) constructs a T2 from
+		// the fields of t in order:
+		// T2(t.x, t.y)
+		value := c.evaluateValueOnce(c.compileExpr(arg), "value")
+		var args []py.Expr
+		for _, field := range c.initFields(c.TypeOf(arg).Underlying().(*types.Struct)) {
+			args = append(args, &py.Attribute{Value: value, Attr: c.memberID(field)})
+		}
+		return &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}, Args: args}
+	}
+	return nil
+}
+
+// convertValue converts the Python value of a Go value with underlying type from
+// to the Python value of underlying type to.
+func convertValue(to types.Type, from types.Type, value py.Expr) py.Expr {
+	switch to := to.(type) {
+	case *types.Basic:
+		fromBasic, _ := from.(*types.Basic)
+		switch {
+		case to.Info()&types.IsInteger != 0:
+			if fromBasic != nil && fromBasic.Info()&types.IsFloat != 0 {
+				return &py.Call{Func: pyInt, Args: []py.Expr{value}}
+			}
+		case to.Info()&types.IsFloat != 0:
+			if fromBasic != nil && fromBasic.Info()&types.IsInteger != 0 {
+				return &py.Call{Func: pyFloat, Args: []py.Expr{value}}
+			}
+		case to.Info()&types.IsString != 0:
+			switch {
+			case fromBasic != nil && fromBasic.Info()&types.IsInteger != 0:
+				return &py.Call{Func: pyChr, Args: []py.Expr{value}}
+			case isByteSlice(from):
+				return &py.Call{Func: &py.Attribute{
+					Value: &py.Call{Func: pyBytes, Args: []py.Expr{value}},
+					Attr:  py.Identifier("decode"),
+				}}
+			case isRuneSlice(from):
+				return &py.Call{
+					Func: &py.Attribute{Value: pyEmptyString, Attr: py.Identifier("join")},
+					Args: []py.Expr{&py.Call{Func: pyMap, Args: []py.Expr{pyChr, value}}},
+				}
+			}
+		}
+	case *types.Slice:
+		if isString(from) {
+			switch {
+			case isByteSlice(to):
+				return &py.Call{Func: pyList, Args: []py.Expr{
+					&py.Call{Func: &py.Attribute{Value: value, Attr: py.Identifier("encode")}},
+				}}
+			case isRuneSlice(to):
+				return &py.Call{Func: pyList, Args: []py.Expr{
+					&py.Call{Func: pyMap, Args: []py.Expr{pyOrd, value}},
+				}}
+			}
+		}
+	}
+	return value
+}
+
+// isRuneSlice reports whether t is a slice of runes.
+func isRuneSlice(t types.Type) bool {
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	elem, ok := slice.Elem().Underlying().(*types.Basic)
+	return ok && elem.Kind() == types.Rune
+}
+
 func (c *exprCompiler) compileCallExpr(expr *ast.CallExpr) py.Expr {
 
+	if c.Types[expr.Fun].IsType() {
+		if compiled := c.compileConversion(expr); compiled != nil {
+			return compiled
+		}
+	}
 	switch fun := expr.Fun.(type) {
 	case *ast.Ident:
 		switch c.ObjectOf(fun) {
+		case builtin.append:
+			var elts py.Expr = &py.List{Elts: c.compileExprs(expr.Args[1:])}
+			if expr.Ellipsis.IsValid() {
+				// append(xs, ys...) becomes xs + list(ys), and the bytes of a
+				// string are appended as list(s.encode())
+				spread := c.compileSlice(expr.Args[1])
+				if isString(c.TypeOf(expr.Args[1])) {
+					spread = &py.Call{Func: &py.Attribute{Value: spread, Attr: py.Identifier("encode")}}
+				}
+				elts = &py.Call{Func: pyList, Args: []py.Expr{spread}}
+			}
+			return &py.BinOp{
+				Left:  c.compileAppendSlice(expr.Args[0]),
+				Op:    py.Add,
+				Right: elts,
+			}
+		case builtin.copy:
+			assign, n := c.compileCopy(expr)
+			c.addStmt(assign)
+			return n
+		case builtin.recover:
+			return &py.Call{Func: goRecover}
 		case builtin.make:
 			typ := expr.Args[0]
 			switch t := c.TypeOf(typ).Underlying().(type) {
 			case *types.Slice:
 				length := expr.Args[1]
+				// Lists grow as they are appended to, so a capacity is not
+				// allocated
+				if value := c.Types[length].Value; value != nil && constant.Sign(value) == 0 {
+					return &py.List{}
+				}
 				// This is a list comprehension rather than [<nil value>] * length
 				// because in the case when T is not a primitive type,
 				// every element in the list needs to be a different object.
@@ -275,7 +763,20 @@ func (c *exprCompiler) compileCallExpr(expr *ast.CallExpr) py.Expr {
 					},
 				}
 			case *types.Map:
+				if named, ok := c.TypeOf(typ).(*types.Named); ok {
+					return &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}}
+				}
+				if c.isSet(t) {
+					return &py.Set{}
+				}
 				return &py.Dict{}
+			case *types.Chan:
+				// runtime.Chan(size, zero)
+				var size py.Expr = &py.Num{N: "0"}
+				if len(expr.Args) > 1 {
+					size = c.compileExpr(expr.Args[1])
+				}
+				return &py.Call{Func: goChan, Args: []py.Expr{size, c.zeroValue(t.Elem())}}
 			default:
 				panic(c.err(expr, "bad type in make(): %T", t))
 			}
@@ -299,44 +800,385 @@ func (c *exprCompiler) compileCallExpr(expr *ast.CallExpr) py.Expr {
 					Func: pyLen,
 					Args: []py.Expr{
 						&py.Call{
-							Func: &py.Attribute{Value: c.compileExpr(expr.Args[0]), Attr: py.Identifier("encode")},
+							Func: &py.Attribute{Value: c.compileUnwrapped(expr.Args[0]), Attr: py.Identifier("encode")},
 							Args: []py.Expr{&py.Str{S: `"utf-8"`}},
 						},
 					},
 				}
+			case isMap(t):
+				// The length of a nil map is 0: len(m or {})
+				return &py.Call{
+					Func: pyLen,
+					Args: []py.Expr{orEmptyMap(c.compileExpr(expr.Args[0]))},
+				}
 			default:
 				return &py.Call{
 					Func: pyLen,
-					Args: c.compileExprs(expr.Args),
+					Args: []py.Expr{c.compileUnwrapped(expr.Args[0])},
 				}
 			}
 		}
+	case *ast.SelectorExpr:
+		if pkg := c.importedPackage(fun.X); pkg != nil {
+			if compiled := c.compilePackageCall(pkg.Path(), fun.Sel.Name, expr); compiled != nil {
+				return compiled
+			}
+		}
+		if sel, ok := c.Selections[fun]; ok && sel.Kind() == types.MethodVal {
+			if named := classRecv(sel.Obj().(*types.Func)); named != nil {
+				// The map may be nil, None, and the function may not have
+				// been converted to its class, so the method is called on
+				// the class: Counts.M(m, ...)
+				method := &py.Attribute{Value: &py.Name{Id: c.objID(named.Obj())}, Attr: c.memberID(sel.Obj())}
+				recv := c.compileExpr(fun.X)
+				return &py.Call{Func: method, Args: append([]py.Expr{recv}, c.compileCallArgs(expr)...)}
+			}
+		}
 	case *ast.ArrayType, *ast.ChanType, *ast.FuncType,
 		*ast.InterfaceType, *ast.MapType, *ast.StructType:
 		// TODO implement type conversions
 		return c.compileExpr(expr.Args[0])
 	}
+	args := c.compileCallArgs(expr)
 	return &py.Call{
 		Func: c.compileExpr(expr.Fun),
-		Args: c.compileExprs(expr.Args),
+		Args: args,
+	}
+}
+
+// compileCallArgs compiles the arguments of the call expr.
+func (c *exprCompiler) compileCallArgs(expr *ast.CallExpr) []py.Expr {
+	args := c.compileExprs(expr.Args)
+	if expr.Ellipsis.IsValid() {
+		// f(xs...) spreads xs into the variadic parameter, and a nil
+		// slice, None, spreads no arguments: f(*(xs or []))
+		last := len(args) - 1
+		spread := args[last]
+		if c.mayBeNil(expr.Args[last]) {
+			spread = orEmptyList(spread)
+		}
+		args[last] = &py.Starred{Value: spread}
 	}
+	return args
 }
+
+// classRecv returns the named map or function type that is the receiver of
+// method, whose values need not be instances of its class, or nil if it has
+// another receiver.
+func classRecv(method *types.Func) *types.Named {
+	recv := method.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	named, ok := recv.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	switch named.Underlying().(type) {
+	case *types.Map, *types.Signature:
+		return named
+	}
+	return nil
+}
+
 func (c *exprCompiler) compileSliceExpr(slice *ast.SliceExpr) py.Expr {
-	return &py.Subscript{
-		Value: c.compileExpr(slice.X),
+	var value py.Expr = &py.Subscript{
+		Value: c.compileUnwrapped(slice.X),
 		Slice: &py.RangeSlice{
 			Lower: c.compileExpr(slice.Low),
 			Upper: c.compileExpr(slice.High),
 		}}
+	if typ := c.TypeOf(slice); isWrapper(typ) {
+		// Slicing a named slice gives the named slice type
+		named := typ.(*types.Named)
+		value = &py.Call{Func: &py.Name{Id: c.objID(named.Obj())}, Args: []py.Expr{value}}
+	}
+	return value
 }
 
 func (c *exprCompiler) compileIndexExpr(expr *ast.IndexExpr) py.Expr {
+	if c.isSet(c.TypeOf(expr.X)) {
+		// The value of every element is struct{}{}
+		if _, ok := c.TypeOf(expr).(*types.Tuple); ok {
+			return makeTuple(pyNone, c.compileSetContains(expr))
+		}
+		return pyNone
+	}
+	if _, ok := c.TypeOf(expr).(*types.Tuple); ok {
+		// Comma-ok form: v, ok := m[k]
+		// becomes v, ok = (m[k], True) if k in (m or {}) else (<zero value>, False)
+		// with the map and key evaluated once
+		typ := c.TypeOf(expr.X).Underlying().(*types.Map)
+		m := c.evaluateValueOnce(c.compileExpr(expr.X), "m")
+		key := c.evaluateValueOnce(c.compileExpr(expr.Index), "key")
+		return &py.IfExp{
+			Test:   &py.Compare{Left: key, Ops: []py.CmpOp{py.In}, Comparators: []py.Expr{orEmptyMap(m)}},
+			Body:   makeTuple(&py.Subscript{Value: m, Slice: &py.Index{Value: key}}, pyTrue),
+			Orelse: makeTuple(c.zeroValue(typ.Elem()), pyFalse),
+		}
+	}
+	if m, ok := c.TypeOf(expr.X).Underlying().(*types.Map); ok {
+		// Reading a missing key gives the zero value
+		return c.compileMapGet(expr, c.zeroValue(m.Elem()))
+	}
+	if isString(c.TypeOf(expr.X)) {
+		// Indexing a string gives a byte: s.encode()[i]
+		return &py.Subscript{
+			Value: &py.Call{Func: &py.Attribute{Value: c.compileUnwrapped(expr.X), Attr: py.Identifier("encode")}},
+			Slice: &py.Index{Value: c.compileExpr(expr.Index)},
+		}
+	}
 	return &py.Subscript{
-		Value: c.compileExpr(expr.X),
+		Value: c.compileUnwrapped(expr.X),
 		Slice: &py.Index{Value: c.compileExpr(expr.Index)},
 	}
 }
 
+// mayBeNil reports whether the slice expr may be nil. Composite literals,
+// slice expressions, the slices that make and append return, and variadic
+// parameters, which are the arguments, are not.
+func (c *Compiler) mayBeNil(expr ast.Expr) bool {
+	if isString(c.TypeOf(expr)) {
+		return false
+	}
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.CompositeLit, *ast.SliceExpr:
+		return false
+	case *ast.CallExpr:
+		if fun, ok := ast.Unparen(expr.Fun).(*ast.Ident); ok {
+			obj := c.ObjectOf(fun)
+			return obj != builtin.make && obj != builtin.append
+		}
+	case *ast.Ident:
+		return !c.isVariadicParam(c.ObjectOf(expr))
+	}
+	return true
+}
+
+// compileSlice compiles the slice expr, which is (xs or []) if it may be
+// nil, None, so that it can be read as an empty list.
+func (c *exprCompiler) compileSlice(expr ast.Expr) py.Expr {
+	value := c.compileExpr(expr)
+	if c.mayBeNil(expr) {
+		return orEmptyList(value)
+	}
+	return value
+}
+
+// orEmptyList returns xs or [], which reads a nil slice, None, as an empty
+// list.
+func orEmptyList(xs py.Expr) py.Expr {
+	return &py.BoolOpExpr{Op: py.Or, Values: []py.Expr{xs, &py.List{}}}
+}
+
+// isVariadicParam reports whether obj is the variadic parameter of the
+// function that declares it.
+func (c *Compiler) isVariadicParam(obj types.Object) bool {
+	if obj == nil || obj.Parent() == nil {
+		return false
+	}
+	for node, scope := range c.Scopes {
+		if scope != obj.Parent() {
+			continue
+		}
+		funcType, ok := node.(*ast.FuncType)
+		if !ok {
+			return false
+		}
+		sig, ok := c.TypeOf(funcType).(*types.Signature)
+		return ok && sig.Variadic() && sig.Params().At(sig.Params().Len()-1) == obj
+	}
+	return false
+}
+
+// compileMapGet compiles the map read m[k] to (m or {}).get(k, dflt).
+func (c *exprCompiler) compileMapGet(expr *ast.IndexExpr, dflt py.Expr) py.Expr {
+	return &py.Call{
+		Func: &py.Attribute{Value: orEmptyMap(c.compileExpr(expr.X)), Attr: py.Identifier("get")},
+		Args: []py.Expr{c.compileExpr(expr.Index), dflt},
+	}
+}
+
+// orEmptyMap returns m or {}, which reads a nil map, None, as an empty map.
+func orEmptyMap(m py.Expr) py.Expr {
+	return &py.BoolOpExpr{Op: py.Or, Values: []py.Expr{m, &py.Dict{}}}
+}
+
+// orEmptySet returns s or set(), which reads a nil map compiled to a set,
+// None, as an empty set.
+func orEmptySet(s py.Expr) py.Expr {
+	return &py.BoolOpExpr{Op: py.Or, Values: []py.Expr{s, &py.Call{Func: pySet}}}
+}
+
+// compileAppendSlice compiles the slice argument of append.
+func (c *exprCompiler) compileAppendSlice(expr ast.Expr) py.Expr {
+	if index, ok := expr.(*ast.IndexExpr); ok {
+		if _, ok := c.TypeOf(index.X).Underlying().(*types.Map); ok {
+			// A missing key is a nil slice, which can be appended to
+			return c.compileMapGet(index, &py.List{})
+		}
+	}
+	// A nil slice can be appended to: (xs or []) + [x]
+	return c.compileSlice(expr)
+}
+
+// compileCopy compiles copy(dst, src) to a statement that assigns the
+// elements of src that fit in dst, and an expression for how many there are:
+// dst[:len(src)] = src[:len(dst)]
+// min(len(dst), len(src))
+// A slice expression a[lo:hi] as dst is assigned through to a:
+// n = min(len(a[lo:hi]), len(src))
+// a[lo:lo + n] = src[:n]
+func (c *exprCompiler) compileCopy(call *ast.CallExpr) (py.Stmt, py.Expr) {
+	length := func(x py.Expr) py.Expr { return &py.Call{Func: pyLen, Args: []py.Expr{x}} }
+	prefix := func(x py.Expr, n py.Expr) py.Expr {
+		return &py.Subscript{Value: x, Slice: &py.RangeSlice{Upper: n}}
+	}
+	if slice, ok := call.Args[0].(*ast.SliceExpr); ok && !isString(c.TypeOf(slice.X)) {
+		a := c.evaluateValueOnce(c.compileCopySlice(slice.X), "dst")
+		var lo py.Expr
+		if slice.Low != nil {
+			lo = c.evaluateValueOnce(c.compileExpr(slice.Low), "lo")
+		}
+		dst := &py.Subscript{Value: a, Slice: &py.RangeSlice{Lower: lo, Upper: c.compileExpr(slice.High)}}
+		src := c.evaluateValueOnce(c.compileCopySource(call.Args[1]), "src")
+		n := c.evaluateValueOnce(&py.Call{Func: pyMin, Args: []py.Expr{length(dst), length(src)}}, "n")
+		var target py.Expr = prefix(a, n)
+		if lo != nil {
+			target = &py.Subscript{Value: a, Slice: &py.RangeSlice{
+				Lower: lo,
+				Upper: &py.BinOp{Left: lo, Op: py.Add, Right: n},
+			}}
+		}
+		return &py.Assign{Targets: []py.Expr{target}, Value: prefix(src, n)}, n
+	}
+	dst := c.evaluateValueOnce(c.compileCopySlice(call.Args[0]), "dst")
+	src := c.evaluateValueOnce(c.compileCopySource(call.Args[1]), "src")
+	assign := &py.Assign{Targets: []py.Expr{prefix(dst, length(src))}, Value: prefix(src, length(dst))}
+	return assign, &py.Call{Func: pyMin, Args: []py.Expr{length(dst), length(src)}}
+}
+
+// compileCopySource compiles the src argument of copy, where the bytes of
+// a string are copied as list(s.encode()).
+func (c *exprCompiler) compileCopySource(expr ast.Expr) py.Expr {
+	if isString(c.TypeOf(expr)) {
+		return &py.Call{Func: pyList, Args: []py.Expr{
+			&py.Call{Func: &py.Attribute{Value: c.compileExpr(expr), Attr: py.Identifier("encode")}},
+		}}
+	}
+	return c.compileCopySlice(expr)
+}
+
+// compileCopySlice compiles a slice that copy copies to or from, which is
+// (xs or []) if it may be nil, None, so that it has no elements.
+func (c *exprCompiler) compileCopySlice(expr ast.Expr) py.Expr {
+	value := c.compileUnwrapped(expr)
+	if c.mayBeNil(expr) {
+		return orEmptyList(value)
+	}
+	return value
+}
+
+// evaluateValueOnce returns value if it can be evaluated again without effects,
+// otherwise a temporary variable that it is assigned to.
+func (c *exprCompiler) evaluateValueOnce(value py.Expr, baseID string) py.Expr {
+	switch value.(type) {
+	case *py.Name, *py.Num, *py.Str, *py.NameConstant:
+		return value
+	}
+	temp := &py.Name{Id: c.tempID(baseID)}
+	c.addStmt(&py.Assign{Targets: []py.Expr{temp}, Value: value})
+	return temp
+}
+
+// compileTarget compiles an expression that is assigned to.
+func (c *exprCompiler) compileTarget(expr ast.Expr) py.Expr {
+	if index, ok := expr.(*ast.IndexExpr); ok {
+		value := c.compileUnwrapped(index.X)
+		if c.isMapWrite(index) {
+			// Assigning to an entry in a nil map panics
+			value = &py.Call{Func: goWritableMap, Args: []py.Expr{value}}
+		}
+		return &py.Subscript{
+			Value: value,
+			Slice: &py.Index{Value: c.compileExpr(index.Index)},
+		}
+	}
+	if c.baseSelection(expr) != nil {
+		// The fields of an inherited struct are attributes of the outer object
+		panic(c.err(expr, "assignment to embedded struct %s is not supported", types.ExprString(expr)))
+	}
+	return c.compileExpr(expr)
+}
+
+// compileAugAssign compiles the update of target by op with value. Python
+// raises KeyError updating a missing key in place, so an entry of a map is
+// updated by reading it with its zero value:
+//
+//	runtime.writableMap(m)[k] = (m or {}).get(k, zero) op value
+func (c *exprCompiler) compileAugAssign(target ast.Expr, op py.Operator, value py.Expr) py.Stmt {
+	index, ok := ast.Unparen(target).(*ast.IndexExpr)
+	if !ok || !c.isMapWrite(index) {
+		return &py.AugAssign{Target: c.compileTarget(target), Value: value, Op: op}
+	}
+	m := c.evaluateValueOnce(c.compileExpr(index.X), "m")
+	key := c.evaluateValueOnce(c.compileExpr(index.Index), "key")
+	get := &py.Call{
+		Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("get")},
+		Args: []py.Expr{key, c.zeroValue(c.TypeOf(index))},
+	}
+	return &py.Assign{
+		Targets: []py.Expr{&py.Subscript{
+			Value: &py.Call{Func: goWritableMap, Args: []py.Expr{m}},
+			Slice: &py.Index{Value: key},
+		}},
+		Value: &py.BinOp{Left: get, Op: op, Right: value},
+	}
+}
+
+// isMapWrite reports whether assigning to expr assigns to an entry in a map
+// that is compiled to a dict.
+func (c *Compiler) isMapWrite(expr *ast.IndexExpr) bool {
+	_, ok := c.TypeOf(expr.X).Underlying().(*types.Map)
+	return ok && !c.isSet(c.TypeOf(expr.X))
+}
+
+// compileTargets compiles the expressions assigned to by one assignment.
+// Go evaluates the operands of several targets before the assigned values,
+// whereas Python evaluates the values first, so operands with calls are
+// evaluated into temporary variables before the assignment.
+func (c *exprCompiler) compileTargets(exprs []ast.Expr) []py.Expr {
+	var pyExprs []py.Expr
+	for _, expr := range exprs {
+		target := c.compileTarget(expr)
+		if len(exprs) > 1 && hasCall(expr) {
+			switch target := target.(type) {
+			case *py.Attribute:
+				target.Value = c.evaluateValueOnce(target.Value, "target")
+			case *py.Subscript:
+				target.Value = c.evaluateValueOnce(target.Value, "target")
+				if index, ok := target.Slice.(*py.Index); ok {
+					index.Value = c.evaluateValueOnce(index.Value, "index")
+				}
+			}
+		}
+		pyExprs = append(pyExprs, target)
+	}
+	return pyExprs
+}
+
+// hasCall reports whether expr makes a call anywhere, such as in the
+// operand of getObj().inner.a or the index of xs[f()].
+func hasCall(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		_, ok := node.(*ast.CallExpr)
+		found = found || ok
+		return !found
+	})
+	return found
+}
+
 func (c *exprCompiler) addStmt(stmt py.Stmt) {
 	c.stmts = append(c.stmts, stmt)
 }
@@ -344,13 +1186,109 @@ func (c *exprCompiler) addStmt(stmt py.Stmt) {
 func (c *exprCompiler) compileFuncLit(expr *ast.FuncLit) py.Expr {
 	id := c.tempID("func")
 	funcDef := c.compileFunc(id, expr.Type, expr.Body, false, nil)
+	if names := c.nonlocals(expr); names != nil {
+		funcDef.Body = append([]py.Stmt{&py.Nonlocal{Names: names}}, funcDef.Body...)
+	}
 	c.addStmt(funcDef)
 	return &py.Name{Id: id}
 }
 
+// nonlocals returns the variables of the enclosing functions that a
+// function literal assigns to, which Python would otherwise make local to it.
+func (c *exprCompiler) nonlocals(lit *ast.FuncLit) []py.Identifier {
+	var names []py.Identifier
+	seen := map[types.Object]bool{}
+	assign := func(expr ast.Expr) {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		if !ok {
+			return
+		}
+		obj, ok := c.Uses[ident].(*types.Var)
+		if !ok || seen[obj] || obj.Parent() == nil || obj.Parent() == obj.Pkg().Scope() {
+			return
+		}
+		if lit.Pos() <= obj.Pos() && obj.Pos() < lit.End() {
+			// Declared in the literal
+			return
+		}
+		seen[obj] = true
+		names = append(names, c.objID(obj))
+	}
+	ast.Inspect(lit.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			// A nested literal declares its own
+			return false
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				assign(lhs)
+			}
+		case *ast.IncDecStmt:
+			assign(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					assign(n.Key)
+				}
+				if n.Value != nil {
+					assign(n.Value)
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
+
 func (c *exprCompiler) compileTypeAssertExpr(expr *ast.TypeAssertExpr) py.Expr {
-	// TODO
-	return c.compileExpr(expr.X)
+	if _, ok := c.TypeOf(expr).(*types.Tuple); !ok {
+		// TODO check the dynamic type
+		return c.compileExpr(expr.X)
+	}
+	// Comma-ok form: v, ok := x.(T)
+	// becomes v, ok = (x, True) if type(x) == T else (<zero value>, False)
+	// with x evaluated once
+	typ := c.TypeOf(expr.Type)
+	class := c.compileDynamicType(expr.Type, typ)
+	value := c.evaluateValueOnce(c.compileExpr(expr.X), "value")
+	return &py.IfExp{
+		Test: &py.Compare{
+			Left:        &py.Call{Func: pyType, Args: []py.Expr{value}},
+			Ops:         []py.CmpOp{py.Eq},
+			Comparators: []py.Expr{class},
+		},
+		Body:   makeTuple(value, pyTrue),
+		Orelse: makeTuple(c.zeroValue(typ), pyFalse),
+	}
+}
+
+// compileDynamicType compiles the Python type of the values that an
+// interface holds when its dynamic type is typ. A pointer to a struct is
+// the struct's object, so *T is the class of T.
+func (c *exprCompiler) compileDynamicType(node ast.Node, typ types.Type) py.Expr {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	switch t := typ.(type) {
+	case *types.Named:
+		if _, ok := t.Underlying().(*types.Struct); ok || isWrapper(t) {
+			return &py.Name{Id: c.objID(t.Obj())}
+		}
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return pyBool
+		case t.Info()&types.IsInteger != 0:
+			return pyInt
+		case t.Info()&types.IsFloat != 0:
+			return pyFloat
+		case t.Info()&types.IsComplex != 0:
+			return pyComplex
+		case t.Info()&types.IsString != 0:
+			return pyStr
+		}
+	}
+	panic(c.err(node, "type assertion to %s is not supported", typ))
 }
 
 func (c *exprCompiler) compileStarExpr(expr *ast.StarExpr) py.Expr {
@@ -362,6 +1300,9 @@ func (c *exprCompiler) compileExpr(expr ast.Expr) py.Expr {
 	if expr == nil {
 		return nil
 	}
+	if wrapped := c.compileWrappedConstant(expr); wrapped != nil {
+		return wrapped
+	}
 	switch e := expr.(type) {
 	case *ast.UnaryExpr:
 		return c.compileUnaryExpr(e)
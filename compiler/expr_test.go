@@ -23,7 +23,9 @@ var (
 )
 
 type U struct{}
+type Point struct{x, y int}
 type IntSlice []int
+type Str string
 
 var (
 	b0, b1 bool
@@ -31,11 +33,26 @@ var (
 	u0, u1 uint
 	xs []int
 	obj interface{}
+	s0, s1 Str
+	str string
+	rs []rune
+	mt map[int]T
+	rw ReadWriter
 )
 
+type Reader interface { Read([]byte) (int, error) }
+type ReadWriter interface {
+	Reader
+	Write([]byte) (int, error)
+}
+
+func (T) M() int { return 0 }
+func ts() []T { return nil }
+
 func f0() int { return 0 }
 func f1(int) int { return 0 }
 func f2(int, int) int { return 0 }
+func fv(int, ...int) int { return 0 }
 
 func id(x interface{}) interface{} { return x }
 
@@ -47,6 +64,7 @@ var (
 	f0 = &py.Name{Id: py.Identifier("f0")}
 	f1 = &py.Name{Id: py.Identifier("f1")}
 	f2 = &py.Name{Id: py.Identifier("f2")}
+	fv = &py.Name{Id: py.Identifier("fv")}
 
 	b0 = &py.Name{Id: py.Identifier("b0")}
 	b1 = &py.Name{Id: py.Identifier("b1")}
@@ -69,6 +87,11 @@ var (
 
 	obj = &py.Name{Id: py.Identifier("obj")}
 	m   = &py.Name{Id: py.Identifier("m")}
+	arr = &py.Name{Id: py.Identifier("arr")}
+
+	s0 = &py.Name{Id: py.Identifier("s0")}
+	s1 = &py.Name{Id: py.Identifier("s1")}
+	rs = &py.Name{Id: py.Identifier("rs")}
 )
 
 var exprTests = []struct {
@@ -85,8 +108,15 @@ var exprTests = []struct {
 
 	// Integer literals
 	{"42", &py.Num{N: "42"}},
-	//{"0600", &py.Num{N: "0o600"}},
+	{"0600", &py.Num{N: "0o600"}},
+	{"0o600", &py.Num{N: "0o600"}},
+	{"0O600", &py.Num{N: "0O600"}},
+	{"0", &py.Num{N: "0"}},
 	{"0xBadFace", &py.Num{N: "0xBadFace"}},
+	{"0b101", &py.Num{N: "0b101"}},
+	{"1_000_000", &py.Num{N: "1000000"}},
+	{"0x_FF", &py.Num{N: "0xFF"}},
+	{"0_600", &py.Num{N: "0o600"}},
 	//{"170141183460469231731687303715884105727", &py.Num{N: "170141183460469231731687303715884105727"}},
 
 	// Floating point literals
@@ -99,6 +129,7 @@ var exprTests = []struct {
 	{"1E6", &py.Num{N: "1E6"}},
 	{".25", &py.Num{N: ".25"}},
 	{".12345E+5", &py.Num{N: ".12345E+5"}},
+	{"1_000.000_1", &py.Num{N: "1000.0001"}},
 
 	// Imaginary literals
 	{"0i", &py.Num{N: "0j"}},
@@ -128,18 +159,20 @@ var exprTests = []struct {
 	{`"\""`, &py.Str{S: `"\""`}},
 
 	// Rune literals
-	{`'a'`, &py.Str{S: `'a'`}},
-	{`'ä'`, &py.Str{S: `'ä'`}},
-	{`'本'`, &py.Str{S: `'本'`}},
-	{`'\t'`, &py.Str{S: `'\t'`}},
-	{`'\000'`, &py.Str{S: `'\000'`}},
-	{`'\007'`, &py.Str{S: `'\007'`}},
-	{`'\377'`, &py.Str{S: `'\377'`}},
-	{`'\x07'`, &py.Str{S: `'\x07'`}},
-	{`'\xff'`, &py.Str{S: `'\xff'`}},
-	{`'\u12e4'`, &py.Str{S: `'\u12e4'`}},
-	{`'\U00101234'`, &py.Str{S: `'\U00101234'`}},
-	{`'\''`, &py.Str{S: `'\''`}},
+	{`'a'`, &py.Num{N: "97"}},
+	{`'\n'`, &py.Num{N: "10"}},
+	{`'\u00e9'`, &py.Num{N: "233"}},
+	{`'ä'`, &py.Num{N: "228"}},
+	{`'本'`, &py.Num{N: "26412"}},
+	{`'\t'`, &py.Num{N: "9"}},
+	{`'\000'`, &py.Num{N: "0"}},
+	{`'\007'`, &py.Num{N: "7"}},
+	{`'\377'`, &py.Num{N: "255"}},
+	{`'\x07'`, &py.Num{N: "7"}},
+	{`'\xff'`, &py.Num{N: "255"}},
+	{`'\u12e4'`, &py.Num{N: "4836"}},
+	{`'\U00101234'`, &py.Num{N: "1053236"}},
+	{`'\''`, &py.Num{N: "39"}},
 
 	// Composite literals
 	{"T{}", &py.Call{Func: T}},
@@ -162,6 +195,12 @@ var exprTests = []struct {
 			&py.Call{Func: T, Args: []py.Expr{z, w}},
 		},
 	}},
+	{"map[int][]int{x: {y, z}, w: {}}", &py.Dict{
+		Keys:   []py.Expr{x, w},
+		Values: []py.Expr{&py.List{Elts: []py.Expr{y, z}}, &py.List{Elts: []py.Expr{}}},
+	}},
+	// Eliding &T gives a pointer, which is the object of T
+	{"[]*T{{x, y}}", &py.List{Elts: []py.Expr{&py.Call{Func: T, Args: []py.Expr{x, y}}}}},
 	{"map[T]U{{x, y}: {}, {z, w}: {}}", &py.Dict{
 		Keys: []py.Expr{
 			&py.Call{Func: T, Args: []py.Expr{x, y}},
@@ -181,6 +220,18 @@ var exprTests = []struct {
 	{"x > y", &py.Compare{Left: x, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.Gt}}},
 	{"x >= y", &py.Compare{Left: x, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.GtE}}},
 
+	// Named string types are compared by their values
+	{"s0 < s1", &py.Compare{
+		Left:        &py.Attribute{Value: s0, Attr: py.Identifier("value")},
+		Comparators: []py.Expr{&py.Attribute{Value: s1, Attr: py.Identifier("value")}},
+		Ops:         []py.CmpOp{py.Lt},
+	}},
+	{`s0 == "a"`, &py.Compare{
+		Left:        &py.Attribute{Value: s0, Attr: py.Identifier("value")},
+		Comparators: []py.Expr{&py.Str{S: `"a"`}},
+		Ops:         []py.CmpOp{py.Eq},
+	}},
+
 	// Arithmetic operators
 	{"x + y", &py.BinOp{Left: x, Right: y, Op: py.Add}},
 	{"x - y", &py.BinOp{Left: x, Right: y, Op: py.Sub}},
@@ -193,11 +244,38 @@ var exprTests = []struct {
 	{"x << u0", &py.BinOp{Left: x, Right: u0, Op: py.LShift}},
 	{"x >> u0", &py.BinOp{Left: x, Right: u0, Op: py.RShift}},
 	{"x &^ y", &py.BinOp{Left: x, Right: &py.UnaryOpExpr{Operand: y, Op: py.Invert}, Op: py.BitAnd}},
+	// Constant shifts are folded, also with an untyped float operand
+	{"1<<8 - 1", &py.Num{N: "255"}},
+	{"1.0 << 3", &py.Num{N: "8"}},
+	{"1 << 100 >> 98", &py.Num{N: "4"}},
+	{"x + 1<<2", &py.BinOp{Left: x, Right: &py.Num{N: "4"}, Op: py.Add}},
+	// Constant string concatenations are a single literal
+	{`"a" + "b" + "c"`, &py.Str{S: `"abc"`}},
+	{`str + "b" + "c"`, &py.BinOp{
+		Left:  &py.BinOp{Left: str, Op: py.Add, Right: &py.Str{S: `"b"`}},
+		Op:    py.Add,
+		Right: &py.Str{S: `"c"`},
+	}},
 
 	// Logical operators
 	{"b0 && b1", &py.BoolOpExpr{Values: []py.Expr{b0, b1}, Op: py.And}},
 	{"b0 || b1", &py.BoolOpExpr{Values: []py.Expr{b0, b1}, Op: py.Or}},
 	{"!b0", &py.UnaryOpExpr{Operand: b0, Op: py.Not}},
+	// Bitwise and logical operators are distinct
+	{"x&y != 0 && b0", &py.BoolOpExpr{
+		Values: []py.Expr{
+			&py.Compare{Left: &py.BinOp{Left: x, Right: y, Op: py.BitAnd}, Comparators: []py.Expr{zero}, Ops: []py.CmpOp{py.NotEq}},
+			b0,
+		},
+		Op: py.And,
+	}},
+	{"x|y == 0 || b0", &py.BoolOpExpr{
+		Values: []py.Expr{
+			&py.Compare{Left: &py.BinOp{Left: x, Right: y, Op: py.BitOr}, Comparators: []py.Expr{zero}, Ops: []py.CmpOp{py.Eq}},
+			b0,
+		},
+		Op: py.Or,
+	}},
 
 	// Address operators
 	{"&x", x},
@@ -211,6 +289,50 @@ var exprTests = []struct {
 	{"+x", &py.UnaryOpExpr{Operand: x, Op: py.UAdd}},
 	{"^x", &py.UnaryOpExpr{Operand: x, Op: py.Invert}}, // TODO incorrect for unsigned
 
+	// Chained index, call and selector expressions
+	{"mt[x].M()", &py.Call{Func: &py.Attribute{
+		Value: &py.Call{
+			Func: &py.Attribute{Value: orEmptyMap(&py.Name{Id: py.Identifier("mt")}), Attr: py.Identifier("get")},
+			Args: []py.Expr{x, &py.Call{Func: T}},
+		},
+		Attr: py.Identifier("M"),
+	}}},
+	{"ts()[0].x", &py.Attribute{
+		Value: &py.Subscript{Value: &py.Call{Func: &py.Name{Id: py.Identifier("ts")}}, Slice: &py.Index{Value: zero}},
+		Attr:  x.Id,
+	}},
+
+	// Conversions
+	{"[]int64{int64(1), int64(x)}", &py.List{Elts: []py.Expr{one, x}}},
+	{"float64(x)", &py.Call{Func: pyFloat, Args: []py.Expr{x}}},
+	{"int(float64(x))", &py.Call{Func: pyInt, Args: []py.Expr{&py.Call{Func: pyFloat, Args: []py.Expr{x}}}}},
+	{"string(rune(x))", &py.Call{Func: pyChr, Args: []py.Expr{x}}},
+	// Indexing a string gives a byte
+	{"str[x]", &py.Subscript{Value: &py.Call{Func: &py.Attribute{Value: str, Attr: py.Identifier("encode")}}, Slice: &py.Index{Value: x}}},
+	{"[]rune(str)", &py.Call{Func: pyList, Args: []py.Expr{&py.Call{Func: pyMap, Args: []py.Expr{pyOrd, str}}}}},
+	{"string(rs)", &py.Call{
+		Func: &py.Attribute{Value: pyEmptyString, Attr: py.Identifier("join")},
+		Args: []py.Expr{&py.Call{Func: pyMap, Args: []py.Expr{pyChr, rs}}},
+	}},
+	{`string([]rune{72, 105})`, &py.Call{
+		Func: &py.Attribute{Value: pyEmptyString, Attr: py.Identifier("join")},
+		Args: []py.Expr{&py.Call{Func: pyMap, Args: []py.Expr{pyChr, &py.List{Elts: []py.Expr{&py.Num{N: "72"}, &py.Num{N: "105"}}}}}},
+	}},
+	{`[]rune("Hi")`, &py.Call{Func: pyList, Args: []py.Expr{&py.Call{Func: pyMap, Args: []py.Expr{pyOrd, &py.Str{S: `"Hi"`}}}}}},
+	{"Str(str)", &py.Call{Func: &py.Name{Id: py.Identifier("Str")}, Args: []py.Expr{str}}},
+	{"string(s0)", &py.Attribute{Value: s0, Attr: py.Identifier("value")}},
+	{"int64(7)", &py.Num{N: "7"}},
+	{"Point(t0)", &py.Call{
+		Func: &py.Name{Id: py.Identifier("Point")},
+		Args: []py.Expr{&py.Attribute{Value: t0, Attr: x.Id}, &py.Attribute{Value: t0, Attr: y.Id}},
+	}},
+	{"Reader(rw)", &py.Name{Id: py.Identifier("rw")}},
+	{"interface{}(x)", x},
+	{"uintptr(x)", x},
+	{"uintptr(len(xs))", &py.Call{Func: pyLen, Args: []py.Expr{xs}}},
+	{"any(x)", x},
+	{"[]any{x, y}", &py.List{Elts: []py.Expr{x, y}}},
+
 	// Selector
 	{"T{}.y", &py.Attribute{
 		Value: &py.Call{
@@ -223,7 +345,13 @@ var exprTests = []struct {
 	{"f0()", &py.Call{Func: f0}},
 	{"f1(y)", &py.Call{Func: f1, Args: []py.Expr{y}}},
 	{"f2(y,z)", &py.Call{Func: f2, Args: []py.Expr{y, z}}},
-	//{"x(y,z...)", &py.Call{Func: x, Args: []py.Expr{y, &py.Starred{Value: z}}}},
+	{"fv(y,z)", &py.Call{Func: fv, Args: []py.Expr{y, z}}},
+	{"fv(y,xs...)", &py.Call{Func: fv, Args: []py.Expr{y, &py.Starred{Value: &py.BoolOpExpr{
+		Op:     py.Or,
+		Values: []py.Expr{xs, &py.List{}},
+	}}}}},
+	{"fv(y,[]int{x}...)", &py.Call{Func: fv, Args: []py.Expr{y, &py.Starred{Value: &py.List{Elts: []py.Expr{x}}}}}},
+	{"fv(y)", &py.Call{Func: fv, Args: []py.Expr{y}}},
 
 	// Index
 	{"xs[y]", &py.Subscript{Value: xs, Slice: &py.Index{Value: y}}},
@@ -262,8 +390,19 @@ var exprTests = []struct {
 					Func: pyRange,
 					Args: []py.Expr{x}},
 			}}}},
+	// The capacity is not allocated
+	{"make([]T, 0, x)", &py.List{}},
+	{"make([]int, 0)", &py.List{}},
 	{"make(map[T]U)", &py.Dict{}},
+	{"make(map[string]int, x)", &py.Dict{}},
+	// A channel is made with its buffer size and the zero value it receives
+	// once closed
+	{"make(chan int)", &py.Call{Func: goChan, Args: []py.Expr{zero, zero}}},
+	{"make(chan string, x)", &py.Call{Func: goChan, Args: []py.Expr{x, &py.Str{S: `""`}}}},
+	{"make(chan *T, 4)", &py.Call{Func: goChan, Args: []py.Expr{&py.Num{N: "4"}, pyNone}}},
 	{"len(xs)", &py.Call{Func: pyLen, Args: []py.Expr{xs}}},
+	// A nil map has no entries
+	{"len(mt)", &py.Call{Func: pyLen, Args: []py.Expr{orEmptyMap(&py.Name{Id: py.Identifier("mt")})}}},
 	{`len("")`, &py.Call{
 		Func: pyLen,
 		Args: []py.Expr{
@@ -299,21 +438,46 @@ func pythonExprCode(expr py.Expr) string {
 }
 
 func buildFile(file string) (*loader.PackageInfo, *ast.File, []error) {
+	return buildFileSet(token.NewFileSet(), file)
+}
+
+// buildFileSet is buildFile with the positions recorded in fset.
+func buildFileSet(fset *token.FileSet, file string) (*loader.PackageInfo, *ast.File, []error) {
+	pkg, errs := buildPackage(fset, file)
+	if pkg == nil {
+		return nil, nil, errs
+	}
+	return pkg, pkg.Files[0], errs
+}
+
+// buildPackage type checks package main made of the source files, which are
+// named a.go, b.go and so on.
+func buildPackage(fset *token.FileSet, files ...string) (*loader.PackageInfo, []error) {
 	var conf loader.Config
 	conf.AllowErrors = true
-	conf.Fset = token.NewFileSet()
-	astFile, err := parser.ParseFile(conf.Fset, "main.go", file, parser.ParseComments)
-	if err != nil {
-		return nil, nil, []error{err}
+	conf.Fset = fset
+	// Only the declarations of imported packages are needed
+	conf.TypeCheckFuncBodies = func(path string) bool { return path == "main" }
+	var astFiles []*ast.File
+	for i, file := range files {
+		name := "main.go"
+		if len(files) > 1 {
+			name = string(rune('a'+i)) + ".go"
+		}
+		astFile, err := parser.ParseFile(conf.Fset, name, file, parser.ParseComments)
+		if err != nil {
+			return nil, []error{err}
+		}
+		astFiles = append(astFiles, astFile)
 	}
 
-	conf.CreateFromFiles("main", astFile)
+	conf.CreateFromFiles("main", astFiles...)
 	program, err := conf.Load()
 	if err != nil {
-		return nil, nil, []error{err}
+		return nil, []error{err}
 	}
 	pkg := program.Package("main")
-	return pkg, pkg.Files[0], pkg.Errors
+	return pkg, pkg.Errors
 }
 
 func TestExpr(t *testing.T) {
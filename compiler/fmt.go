@@ -0,0 +1,312 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+var pyEnd = py.Identifier("end")
+
+// compileFmtCall compiles calls to the printing functions in package fmt
+// to Python's print function and % formatting.
+func (c *exprCompiler) compileFmtCall(name string, expr *ast.CallExpr) py.Expr {
+	switch name {
+	case "Println":
+		return &py.Call{Func: pyPrint, Args: c.compilePrintArgs(expr.Args)}
+	case "Printf":
+		return &py.Call{
+			Func:     pyPrint,
+			Args:     []py.Expr{c.compileFormat(expr)},
+			Keywords: []py.Keyword{{Arg: &pyEnd, Value: pyEmptyString}},
+		}
+	case "Sprintf":
+		return c.compileFormat(expr)
+	case "Print":
+		return &py.Call{
+			Func:     pyPrint,
+			Args:     []py.Expr{c.compileSprint(expr.Args)},
+			Keywords: []py.Keyword{{Arg: &pyEnd, Value: pyEmptyString}},
+		}
+	case "Sprint":
+		return c.compileSprint(expr.Args)
+	case "Sprintln":
+		return c.compileSprintln(expr.Args)
+	}
+	return nil
+}
+
+// compileSprint compiles the operands of fmt.Sprint to a Python % formatting
+// expression, with a space between operands when neither is a string.
+// Whether an interface holds a string is only known at run time, so those
+// operands are formatted by runtime.sprint.
+func (c *exprCompiler) compileSprint(args []ast.Expr) py.Expr {
+	for _, arg := range args {
+		if types.IsInterface(c.TypeOf(arg)) {
+			return &py.Call{Func: goSprint, Args: c.compileExprs(args)}
+		}
+	}
+	var format []string
+	for i, arg := range args {
+		if i > 0 && !isString(c.TypeOf(arg)) && !isString(c.TypeOf(args[i-1])) {
+			format = append(format, " ")
+		}
+		format = append(format, "%s")
+	}
+	return c.compileValueFormat(strings.Join(format, ""), args)
+}
+
+// compileSprintln compiles the operands of fmt.Sprintln to a Python %
+// formatting expression, with a space between every operand and a newline
+// after the last.
+func (c *exprCompiler) compileSprintln(args []ast.Expr) py.Expr {
+	format := strings.Repeat("%s ", len(args))
+	format = strings.TrimSuffix(format, " ") + "\n"
+	return c.compileValueFormat(format, args)
+}
+
+// compileValueFormat formats each of args with a %s verb of format as Go's
+// %v does.
+func (c *exprCompiler) compileValueFormat(format string, args []ast.Expr) py.Expr {
+	if len(args) == 0 {
+		return &py.Str{S: strconv.Quote(format)}
+	}
+	return &py.BinOp{
+		Left:  &py.Str{S: strconv.Quote(format)},
+		Op:    py.Mod,
+		Right: &py.Tuple{Elts: c.compilePrintArgs(args)},
+	}
+}
+
+// compilePrintArgs compiles the operands of fmt.Println, each converted to
+// what Python formats as Go's %v does.
+func (c *exprCompiler) compilePrintArgs(goArgs []ast.Expr) []py.Expr {
+	var args []py.Expr
+	for _, arg := range goArgs {
+		args = append(args, c.formatValue(c.compileUnwrapped(arg), c.TypeOf(arg)))
+	}
+	return args
+}
+
+// compileFormat translates the Go format string and arguments of a call to
+// fmt.Printf or fmt.Sprintf into a Python % formatting expression.
+// A format that is not constant, or arguments spread from a slice, are
+// formatted at run time:
+// runtime.sprintf(format, *args)
+func (c *exprCompiler) compileFormat(call *ast.CallExpr) py.Expr {
+	format, args := call.Args[0], call.Args[1:]
+	value := c.Types[format].Value
+	if value == nil || value.Kind() != constant.String || call.Ellipsis.IsValid() {
+		pyArgs := append([]py.Expr{c.compileExpr(format)}, c.compileExprs(args)...)
+		if call.Ellipsis.IsValid() {
+			last := len(pyArgs) - 1
+			pyArgs[last] = &py.Starred{Value: pyArgs[last]}
+		}
+		return &py.Call{Func: goSprintf, Args: pyArgs}
+	}
+	goFormat := constant.StringVal(value)
+	var pyFormat []rune
+	var pyArgs []py.Expr
+	runes := []rune(goFormat)
+	for i := 0; i < len(runes); i++ {
+		pyFormat = append(pyFormat, runes[i])
+		if runes[i] != '%' {
+			continue
+		}
+		i++
+		// Flags, width and precision mean the same to Python, and a * takes
+		// the width or precision from the next argument in both
+		for ; i < len(runes) && strings.ContainsRune("+-# 0123456789.*", runes[i]); i++ {
+			if runes[i] == '*' {
+				if len(pyArgs) == len(args) {
+					panic(c.err(format, "missing argument for *"))
+				}
+				pyArgs = append(pyArgs, c.compileExpr(args[len(pyArgs)]))
+			}
+			pyFormat = append(pyFormat, runes[i])
+		}
+		if i == len(runes) {
+			panic(c.err(format, "missing verb at end of format string"))
+		}
+		verb := runes[i]
+		if verb == '%' {
+			pyFormat = append(pyFormat, verb)
+			continue
+		}
+		if len(pyArgs) == len(args) {
+			panic(c.err(format, "missing argument for %%%c", verb))
+		}
+		goArg := args[len(pyArgs)]
+		arg := c.compileExpr(goArg)
+		switch verb {
+		case 's':
+			if isByteSlice(c.TypeOf(goArg)) {
+				// Byte slices are lists of ints so decode them to a str
+				arg = decodeBytes(arg)
+			}
+		case 'd', 'x', 'X', 'o', 'e', 'E', 'f', 'F', 'g', 'G', 'c':
+		case 'v':
+			verb = 's'
+			arg = c.formatValue(arg, c.TypeOf(goArg))
+		case 't':
+			verb = 's'
+			arg = formatBool(arg)
+		case 'q':
+			verb = 's'
+			if isByteSlice(c.TypeOf(goArg)) {
+				arg = decodeBytes(arg)
+			}
+			arg = &py.Call{Func: goQuote, Args: []py.Expr{arg}}
+		case 'T':
+			// The Go name of the type, which for an interface is the name of
+			// its dynamic type that the runtime knows
+			verb = 's'
+			if isInterface(c.TypeOf(goArg)) {
+				arg = &py.Call{Func: goTypeName, Args: []py.Expr{arg}}
+			} else {
+				arg = &py.Str{S: strconv.Quote(typeName(c.TypeOf(goArg)))}
+			}
+		default:
+			panic(c.err(format, "unsupported verb %%%c", verb))
+		}
+		pyFormat = append(pyFormat, verb)
+		pyArgs = append(pyArgs, arg)
+	}
+	if len(pyArgs) == 0 {
+		return &py.Str{S: strconv.Quote(strings.Replace(goFormat, "%%", "%", -1))}
+	}
+	return &py.BinOp{
+		Left:  &py.Str{S: strconv.Quote(string(pyFormat))},
+		Op:    py.Mod,
+		Right: &py.Tuple{Elts: pyArgs},
+	}
+}
+
+// decodeBytes decodes a byte slice, which is a list of ints, to a str.
+func decodeBytes(b py.Expr) py.Expr {
+	return &py.Call{
+		Func: &py.Attribute{
+			Value: &py.Call{Func: pyBytes, Args: []py.Expr{b}},
+			Attr:  py.Identifier("decode"),
+		},
+	}
+}
+
+// formatBool formats a bool as Go does:
+// "true" if b else "false"
+func formatBool(b py.Expr) py.Expr {
+	return &py.IfExp{Test: b, Body: &py.Str{S: `"true"`}, Orelse: &py.Str{S: `"false"`}}
+}
+
+// formatValue converts arg of type typ to what Python's %s formats as Go's
+// %v does, where they differ for bools and nil. Other composite values are
+// formatted the Python way, so a slice is [1, 2] rather than [1 2].
+func (c *exprCompiler) formatValue(arg py.Expr, typ types.Type) py.Expr {
+	if t, ok := typ.Underlying().(*types.Basic); ok {
+		if t.Info()&types.IsBoolean != 0 {
+			return formatBool(arg)
+		}
+		if t.Kind() == types.UntypedNil {
+			return &py.Str{S: `"<nil>"`}
+		}
+	}
+	if formatsAtRuntime(typ) {
+		// Whether it is nil or holds a bool is known at run time
+		return &py.Call{Func: goFormatValue, Args: []py.Expr{arg}}
+	}
+	return arg
+}
+
+// typeName returns the name of typ as Go's %T formats it, such as
+// *main.T or []int.
+func typeName(typ types.Type) string {
+	return types.TypeString(types.Default(typ), (*types.Package).Name)
+}
+
+// formatsAtRuntime reports whether a value of type typ is formatted by
+// runtime.formatValue because it may be nil or hold a bool.
+func formatsAtRuntime(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Interface, *types.Pointer, *types.Chan, *types.Signature:
+		return true
+	}
+	return false
+}
+
+// formatUsesRuntime reports whether call is a call to a printing function
+// of package fmt that formats an argument with a runtime function.
+func (c *Compiler) formatUsesRuntime(call *ast.CallExpr) bool {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if pkg := c.importedPackage(sel.X); pkg == nil || pkg.Path() != "fmt" {
+		return false
+	}
+	switch sel.Sel.Name {
+	case "Println", "Print", "Sprint", "Sprintln":
+		for _, arg := range call.Args {
+			if formatsAtRuntime(c.TypeOf(arg)) {
+				return true
+			}
+		}
+		return false
+	case "Printf", "Sprintf":
+	default:
+		return false
+	}
+	value := c.Types[call.Args[0]].Value
+	if value == nil || value.Kind() != constant.String || call.Ellipsis.IsValid() {
+		return true
+	}
+	args := call.Args[1:]
+	for i, verb := range formatVerbs(constant.StringVal(value)) {
+		switch verb {
+		case 'q':
+			return true
+		case 'T':
+			if i < len(args) && isInterface(c.TypeOf(args[i])) {
+				return true
+			}
+		case 'v':
+			if i < len(args) && formatsAtRuntime(c.TypeOf(args[i])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatVerbs returns the verb of each argument that a Go format string
+// formats, which is * for a width or precision argument.
+func formatVerbs(format string) []rune {
+	var verbs []rune
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			continue
+		}
+		for i++; i < len(runes) && strings.ContainsRune("+-# 0123456789.*", runes[i]); i++ {
+			if runes[i] == '*' {
+				verbs = append(verbs, '*')
+			}
+		}
+		if i < len(runes) && runes[i] != '%' {
+			verbs = append(verbs, runes[i])
+		}
+	}
+	return verbs
+}
+
+// isByteSlice reports whether t is a slice of bytes.
+func isByteSlice(t types.Type) bool {
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	elem, ok := slice.Elem().Underlying().(*types.Basic)
+	return ok && elem.Kind() == types.Byte
+}
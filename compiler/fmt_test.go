@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"testing"
+)
+
+var (
+	str = &py.Name{Id: py.Identifier("str")}
+	r   = &py.Name{Id: py.Identifier("r")}
+)
+
+func format(f string, args ...py.Expr) py.Expr {
+	return &py.BinOp{Left: &py.Str{S: f}, Op: py.Mod, Right: &py.Tuple{Elts: args}}
+}
+
+func formatValueCall(arg py.Expr) py.Expr {
+	return &py.Call{Func: goFormatValue, Args: []py.Expr{arg}}
+}
+
+func printCall(args ...py.Expr) py.Expr {
+	return &py.Call{Func: pyPrint, Args: args}
+}
+
+func printNoNewline(arg py.Expr) py.Expr {
+	return &py.Call{
+		Func:     pyPrint,
+		Args:     []py.Expr{arg},
+		Keywords: []py.Keyword{{Arg: &pyEnd, Value: pyEmptyString}},
+	}
+}
+
+func assignStr(value py.Expr) []py.Stmt {
+	return []py.Stmt{&py.Assign{Targets: []py.Expr{str}, Value: value}}
+}
+
+func exprStmt(value py.Expr) []py.Stmt {
+	return []py.Stmt{&py.ExprStmt{Value: value}}
+}
+
+var okName = &py.Name{Id: py.Identifier("ok")}
+
+var fmtTests = []stmtTest{
+	{`fmt.Println(x, y)`, exprStmt(printCall(x, y))},
+	{`fmt.Println()`, exprStmt(printCall())},
+	// Bools and nil are formatted as Go formats them
+	{`fmt.Println(ok, obj)`, exprStmt(printCall(formatBool(okName), formatValueCall(obj)))},
+	{`fmt.Printf("%d\n", x)`, exprStmt(printNoNewline(format(`"%d\n"`, x)))},
+	{`fmt.Printf("100%%")`, exprStmt(printNoNewline(&py.Str{S: `"100%"`}))},
+	{`str = fmt.Sprintf("%v and %s", x, str)`, assignStr(format(`"%s and %s"`, x, str))},
+	{`str = fmt.Sprintf("%d%%", x)`, assignStr(format(`"%d%%"`, x))},
+	{`str = fmt.Sprintf("%q", str)`, assignStr(format(`"%s"`, &py.Call{Func: goQuote, Args: []py.Expr{str}}))},
+	{`str = fmt.Sprintf("%t", ok)`, assignStr(format(`"%s"`, formatBool(okName)))},
+	{`str = fmt.Sprintf("%v", ok)`, assignStr(format(`"%s"`, formatBool(okName)))},
+	{`str = fmt.Sprintf("%v", obj)`, assignStr(format(`"%s"`, formatValueCall(obj)))},
+	// %T is the Go name of the type, which for an interface is known at run
+	// time
+	{`str = fmt.Sprintf("%T", t0)`, assignStr(format(`"%s"`, &py.Str{S: `"main.T"`}))},
+	{`str = fmt.Sprintf("%T", obj)`, assignStr(format(`"%s"`, &py.Call{Func: goTypeName, Args: []py.Expr{obj}}))},
+	{`str = fmt.Sprintf("%s", []byte{72, 105})`, assignStr(format(`"%s"`, &py.Call{
+		Func: &py.Attribute{
+			Value: &py.Call{Func: pyBytes, Args: []py.Expr{&py.List{Elts: []py.Expr{&py.Num{N: "72"}, &py.Num{N: "105"}}}}},
+			Attr:  py.Identifier("decode"),
+		},
+	}))},
+	// Flags, width and precision are kept
+	{`str = fmt.Sprintf("%.2f|%05d|%-10s|", 1.5, x, str)`, assignStr(format(`"%.2f|%05d|%-10s|"`, &py.Num{N: "1.5"}, x, str))},
+	{`str = fmt.Sprintf("%6.2f %+d %x", 1.5, x, y)`, assignStr(format(`"%6.2f %+d %x"`, &py.Num{N: "1.5"}, x, y))},
+	{`str = fmt.Sprintf("%*d", y, x)`, assignStr(format(`"%*d"`, y, x))},
+	// A format only known at run time is translated at run time
+	{`str = fmt.Sprintf(str, x)`, assignStr(&py.Call{Func: goSprintf, Args: []py.Expr{str, x}})},
+	{`str = fmt.Sprintf("%d %d", []interface{}{x, y}...)`, assignStr(&py.Call{Func: goSprintf, Args: []py.Expr{
+		&py.Str{S: `"%d %d"`},
+		&py.Starred{Value: &py.List{Elts: []py.Expr{x, y}}},
+	}})},
+	{`str = fmt.Sprint(x, y, str, x, ok)`, assignStr(format(`"%s %s%s%s %s"`, x, y, str, x, formatBool(okName)))},
+	{`str = fmt.Sprint()`, assignStr(&py.Str{S: `""`})},
+	{`str = fmt.Sprint(x, obj)`, assignStr(&py.Call{Func: goSprint, Args: []py.Expr{x, obj}})},
+	{`str = fmt.Sprintln(str, x, ok)`, assignStr(format(`"%s %s %s\n"`, str, x, formatBool(okName)))},
+	{`str = fmt.Sprintln()`, assignStr(&py.Str{S: `"\n"`})},
+	{`fmt.Print(x, y)`, exprStmt(printNoNewline(format(`"%s %s"`, x, y)))},
+}
+
+func TestFmt(t *testing.T) {
+	runStmtTests(t, fmtTests, Options{})
+}
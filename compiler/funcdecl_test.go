@@ -42,10 +42,12 @@ func s(...interface{}) {}
 
 var noClass py.Identifier
 
-var funcDeclTests = []struct {
+type funcDeclTest struct {
 	golang string
 	python FuncDecl
-}{
+}
+
+var funcDeclTests = []funcDeclTest{
 	// Function decl
 	{"func f() {}", FuncDecl{noClass, &py.FunctionDef{Name: f, Body: []py.Stmt{&py.Pass{}}}}},
 	{"func f() {s(0)}", FuncDecl{noClass, &py.FunctionDef{Name: f, Body: s(0)}}},
@@ -63,6 +65,23 @@ var funcDeclTests = []struct {
 			Args: []py.Arg{py.Arg{Arg: x.Id}},
 		},
 	}}},
+	// A variadic parameter is a tuple, which is made a list to assign to
+	{"func f(x ...int) {s(x[0])}", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: s(&py.Subscript{Value: x, Slice: &py.Index{Value: &py.Num{N: "0"}}}),
+		Args: py.Arguments{Vararg: &py.Arg{Arg: x.Id}},
+	}}},
+	{"func f(x ...int) {x[0] = 1}", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{x}, Value: &py.Call{Func: pyList, Args: []py.Expr{x}}},
+			&py.Assign{
+				Targets: []py.Expr{&py.Subscript{Value: x, Slice: &py.Index{Value: &py.Num{N: "0"}}}},
+				Value:   &py.Num{N: "1"},
+			},
+		},
+		Args: py.Arguments{Vararg: &py.Arg{Arg: x.Id}},
+	}}},
 	{"func (x T) f() {s(0)}", FuncDecl{T.Id, &py.FunctionDef{
 		Name: f,
 		Body: s(0),
@@ -81,6 +100,27 @@ var funcDeclTests = []struct {
 			},
 		},
 	}}},
+	// A value receiver is copied if the method assigns to it or returns it
+	{"func (x T) f() T { x.y++; return x }", FuncDecl{T.Id, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{x}, Value: &py.Call{Func: T, Args: []py.Expr{
+				&py.Attribute{Value: x, Attr: x.Id},
+				&py.Attribute{Value: x, Attr: y.Id},
+			}}},
+			&py.AugAssign{Target: &py.Attribute{Value: x, Attr: y.Id}, Op: py.Add, Value: one},
+			&py.Return{Value: x},
+		},
+		Args: py.Arguments{Args: []py.Arg{py.Arg{Arg: x.Id}}},
+	}}},
+	{"func (x *T) f() *T { x.y++; return x }", FuncDecl{T.Id, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.AugAssign{Target: &py.Attribute{Value: x, Attr: y.Id}, Op: py.Add, Value: one},
+			&py.Return{Value: x},
+		},
+		Args: py.Arguments{Args: []py.Arg{py.Arg{Arg: x.Id}}},
+	}}},
 	{"func (T) f() {s(0)}", FuncDecl{T.Id, &py.FunctionDef{
 		Name: f,
 		Body: s(0),
@@ -91,6 +131,22 @@ var funcDeclTests = []struct {
 		},
 	}}},
 
+	// any is interface{}, and neither is annotated
+	{"func f(x any) any { var y any; s(x); return y }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{&py.Assign{Targets: []py.Expr{y}, Value: pyNone}, s(x)[0], &py.Return{Value: y}},
+		Args: py.Arguments{Args: []py.Arg{py.Arg{Arg: x.Id}}},
+	}}},
+	{"func f(x interface{}) interface{} { var y interface{}; s(x); return y }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{&py.Assign{Targets: []py.Expr{y}, Value: pyNone}, s(x)[0], &py.Return{Value: y}},
+		Args: py.Arguments{Args: []py.Arg{py.Arg{Arg: x.Id}}},
+	}}},
+	{"func f() (y any) { return y }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{&py.Assign{Targets: []py.Expr{y}, Value: pyNone}, &py.Return{Value: y}},
+	}}},
+
 	// Return
 	{"func f() { return }", FuncDecl{noClass, &py.FunctionDef{
 		Name: f,
@@ -98,6 +154,39 @@ var funcDeclTests = []struct {
 			&py.Return{},
 		},
 	}}},
+	{"func f() (int, bool) { v, ok := m[x]; return v, ok }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("v")}, &py.Name{Id: py.Identifier("ok")}},
+				Value: &py.IfExp{
+					Test:   &py.Compare{Left: x, Ops: []py.CmpOp{py.In}, Comparators: []py.Expr{orEmptyMap(m)}},
+					Body:   &py.Tuple{Elts: []py.Expr{&py.Subscript{Value: m, Slice: &py.Index{Value: x}}, pyTrue}},
+					Orelse: &py.Tuple{Elts: []py.Expr{zero, pyFalse}},
+				},
+			},
+			&py.Return{Value: &py.Tuple{Elts: []py.Expr{&py.Name{Id: py.Identifier("v")}, &py.Name{Id: py.Identifier("ok")}}}},
+		},
+	}}},
+	// The key is evaluated once
+	{"func f() (int, bool) { v, ok := m[f1(x)]; return v, ok }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("key")}},
+				Value:   &py.Call{Func: &py.Name{Id: py.Identifier("f1")}, Args: []py.Expr{x}},
+			},
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("v")}, &py.Name{Id: py.Identifier("ok")}},
+				Value: &py.IfExp{
+					Test:   &py.Compare{Left: &py.Name{Id: py.Identifier("key")}, Ops: []py.CmpOp{py.In}, Comparators: []py.Expr{orEmptyMap(m)}},
+					Body:   &py.Tuple{Elts: []py.Expr{&py.Subscript{Value: m, Slice: &py.Index{Value: &py.Name{Id: py.Identifier("key")}}}, pyTrue}},
+					Orelse: &py.Tuple{Elts: []py.Expr{zero, pyFalse}},
+				},
+			},
+			&py.Return{Value: &py.Tuple{Elts: []py.Expr{&py.Name{Id: py.Identifier("v")}, &py.Name{Id: py.Identifier("ok")}}}},
+		},
+	}}},
 	// TODO named return values
 	// {"func f() (x int) { return }", FuncDecl{noClass, &py.FunctionDef{
 	// 	Name: f,
@@ -133,6 +222,25 @@ var funcDeclTests = []struct {
 		},
 	}}},
 
+	// Redeclaration in a short variable declaration assigns to the existing variable
+	{"func f() { a, b := g2(); a, c := g2(); _, _, _ = a, b, c }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("a")}, &py.Name{Id: py.Identifier("b")}},
+				Value:   &py.Call{Func: g2},
+			},
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("a")}, &py.Name{Id: py.Identifier("c")}},
+				Value:   &py.Call{Func: g2},
+			},
+			&py.Assign{
+				Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}, &py.Name{Id: py.Identifier("_")}, &py.Name{Id: py.Identifier("_")}},
+				Value:   &py.Tuple{Elts: []py.Expr{&py.Name{Id: py.Identifier("a")}, &py.Name{Id: py.Identifier("b")}, &py.Name{Id: py.Identifier("c")}}},
+			},
+		},
+	}}},
+
 	// Function literals
 	{"func f() { x := 1; func(y int) { _ = x; _ = y }(1) }", FuncDecl{noClass, &py.FunctionDef{
 		Name: f,
@@ -195,29 +303,187 @@ var funcDeclTests = []struct {
 		Name: f,
 		Body: []py.Stmt{
 			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
-			&py.Try{
-				Body: []py.Stmt{
-					&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("x")}}, Value: one},
-					&py.ExprStmt{&py.Call{
-						Func: &py.Attribute{Value: &py.Name{Id: py.Identifier("defers")}, Attr: py.Identifier("append")},
-						Args: []py.Expr{&py.Tuple{Elts: []py.Expr{&py.Name{Id: py.Identifier("ignore")}, &py.Tuple{Elts: []py.Expr{&py.Name{Id: py.Identifier("x")}}}}}},
-					}},
-					&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}}, Value: &py.Name{Id: py.Identifier("x")}},
+			tryDefers([]py.Stmt{
+				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("x")}}, Value: one},
+				deferCall(&py.Name{Id: py.Identifier("ignore")}, &py.Name{Id: py.Identifier("x")}),
+				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}}, Value: &py.Name{Id: py.Identifier("x")}},
+			}),
+		},
+	}}},
+	// A deferred close is the bound close method of the channel
+	{"func f(c chan int) { defer close(c) }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Args: py.Arguments{Args: []py.Arg{{Arg: py.Identifier("c")}}},
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				deferCall(&py.Attribute{Value: &py.Name{Id: py.Identifier("c")}, Attr: py.Identifier("close")}),
+			}),
+		},
+	}}},
+	// The receiver is bound when the defer statement executes
+	{"func (T) m() {}; func f() { t := T{}; defer t.m(); t = T{} }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("t")}}, Value: &py.Call{Func: T}},
+				&py.ExprStmt{Value: &py.Call{
+					Func: &py.Attribute{Value: &py.Name{Id: py.Identifier("defers")}, Attr: py.Identifier("append")},
+					Args: []py.Expr{&py.Tuple{Elts: []py.Expr{
+						&py.Attribute{Value: &py.Name{Id: py.Identifier("t")}, Attr: py.Identifier("m")},
+						&py.Tuple{},
+					}}},
+				}},
+				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("t")}}, Value: &py.Call{Func: T}},
+			}),
+		},
+	}}},
+	// A deferred recover stops a panic and the function returns zero values
+	{"func f() (int, string) { defer func() { s(recover()) }(); panic(1) }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				&py.FunctionDef{
+					Name: py.Identifier("func"),
+					Body: []py.Stmt{&py.ExprStmt{Value: &py.Call{
+						Func: &py.Name{Id: py.Identifier("s")},
+						Args: []py.Expr{&py.Call{Func: goRecover}},
+					}}},
 				},
-				Finalbody: []py.Stmt{
-					&py.For{
-						Target: &py.Tuple{Elts: []py.Expr{&py.Name{Id: "fun"}, &py.Name{Id: "args"}}},
-						Iter:   &py.Call{Func: pyReversed, Args: []py.Expr{&py.Name{Id: py.Identifier("defers")}}},
-						Body:   []py.Stmt{&py.ExprStmt{&py.Call{Func: &py.Name{Id: "fun"}, Args: []py.Expr{&py.Starred{Value: &py.Name{Id: "args"}}}}}},
+				deferCall(&py.Name{Id: py.Identifier("func")}),
+				&py.Raise{Exc: &py.Call{Func: goPanic, Args: []py.Expr{one}}},
+			}, zero, pyEmptyString),
+		},
+	}}},
+	// A function that recovers returns its named results, which start as
+	// zero values and which the deferred function can set
+	{`func f() (r int) { defer func() { recover(); r = 5 }(); r = 3; panic("x") }`, FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				&py.Assign{Targets: []py.Expr{r}, Value: zero},
+				&py.FunctionDef{
+					Name: py.Identifier("func"),
+					Body: []py.Stmt{
+						&py.Nonlocal{Names: []py.Identifier{r.Id}},
+						&py.ExprStmt{Value: &py.Call{Func: goRecover}},
+						&py.Assign{Targets: []py.Expr{r}, Value: &py.Num{N: "5"}},
 					},
 				},
-			},
+				deferCall(&py.Name{Id: py.Identifier("func")}),
+				&py.Assign{Targets: []py.Expr{r}, Value: &py.Num{N: "3"}},
+				&py.Raise{Exc: &py.Call{Func: goPanic, Args: []py.Expr{&py.Str{S: `"x"`}}}},
+			}, r),
+		},
+	}}},
+	// A return assigns the named results before the deferred functions,
+	// which may set them, are called
+	{"func f() (r int) { defer ignore(r); return 3 }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				&py.Assign{Targets: []py.Expr{r}, Value: zero},
+				deferCall(&py.Name{Id: py.Identifier("ignore")}, r),
+				&py.Assign{Targets: []py.Expr{r}, Value: &py.Num{N: "3"}},
+				callDefers(),
+				&py.Return{Value: r},
+			}, r),
+		},
+	}}},
+	// Without a panic recover returns nil
+	{"func f() { defer s(recover() == nil) }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("defers")}}, Value: &py.List{}},
+			tryDefers([]py.Stmt{
+				deferCall(&py.Name{Id: py.Identifier("s")}, &py.Compare{
+					Left:        &py.Call{Func: goRecover},
+					Ops:         []py.CmpOp{py.Eq},
+					Comparators: []py.Expr{pyNone},
+				}),
+			}),
+		},
+	}}},
+	// A function with gotos runs its blocks in a dispatch loop
+	{"func f() { s(0); L: s(1); if b0 { goto L }; s(2) }", FuncDecl{noClass, &py.FunctionDef{
+		Name: f,
+		Body: []py.Stmt{
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("goto")}}, Value: zero},
+			&py.While{Test: pyTrue, Body: []py.Stmt{
+				&py.If{
+					Test: &py.Compare{Left: &py.Name{Id: py.Identifier("goto")}, Ops: []py.CmpOp{py.LtE}, Comparators: []py.Expr{zero}},
+					Body: s(0),
+				},
+				&py.If{
+					Test: &py.Compare{Left: &py.Name{Id: py.Identifier("goto")}, Ops: []py.CmpOp{py.LtE}, Comparators: []py.Expr{one}},
+					Body: []py.Stmt{
+						s(1)[0],
+						&py.If{Test: b0, Body: []py.Stmt{
+							&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("goto")}}, Value: one},
+							&py.Continue{},
+						}},
+						s(2)[0],
+					},
+				},
+				&py.Break{},
+			}},
 		},
 	}}},
 }
 
-func TestFuncDecl(t *testing.T) {
-	for _, test := range funcDeclTests {
+// deferCall returns the registration of a deferred call of fun with args.
+func deferCall(fun py.Expr, args ...py.Expr) py.Stmt {
+	return &py.ExprStmt{Value: &py.Call{
+		Func: &py.Attribute{Value: &py.Name{Id: py.Identifier("defers")}, Attr: py.Identifier("append")},
+		Args: []py.Expr{&py.Tuple{Elts: []py.Expr{fun, &py.Tuple{Elts: args}}}},
+	}}
+}
+
+// callDefers returns the loop that calls the deferred functions.
+func callDefers() py.Stmt {
+	defers := &py.Name{Id: py.Identifier("defers")}
+	fun := &py.Name{Id: py.Identifier("fun")}
+	args := &py.Name{Id: py.Identifier("args")}
+	return &py.While{Test: defers, Body: []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{&py.Tuple{Elts: []py.Expr{fun, args}}},
+			Value:   &py.Call{Func: &py.Attribute{Value: defers, Attr: py.Identifier("pop")}},
+		},
+		&py.ExprStmt{Value: &py.Call{Func: fun, Args: []py.Expr{&py.Starred{Value: args}}}},
+	}}
+}
+
+// tryDefers returns body in the try statement that calls the deferred
+// functions of a function that returns results if it recovers from a panic.
+func tryDefers(body []py.Stmt, results ...py.Expr) py.Stmt {
+	panicking := &py.Name{Id: py.Identifier("panic")}
+	callDefers := callDefers()
+	recovered := []py.Stmt{
+		&py.With{
+			Items: []py.WithItem{{ContextExpr: &py.Call{Func: goAsPanic, Args: []py.Expr{panicking}}, OptionalVars: panicking}},
+			Body:  []py.Stmt{callDefers},
+		},
+		&py.If{
+			Test: &py.UnaryOpExpr{Op: py.Not, Operand: &py.Attribute{Value: panicking, Attr: py.Identifier("recovered")}},
+			Body: []py.Stmt{&py.Raise{}},
+		},
+	}
+	if len(results) > 0 {
+		recovered = append(recovered, &py.Return{Value: makeTuple(results...)})
+	}
+	return &py.Try{
+		Body:      body,
+		Handlers:  []py.ExceptHandler{{Typ: pyException, Name: panicking.Id, Body: recovered}},
+		Finalbody: []py.Stmt{callDefers},
+	}
+}
+
+func runFuncDeclTests(t *testing.T, tests []funcDeclTest, options Options) {
+	for _, test := range tests {
 		t.Run(test.golang, func(t *testing.T) {
 			pkg, file, errs := buildFile(fmt.Sprintf(funcDeclPkgTemplate, test.golang))
 			if errs != nil {
@@ -229,6 +495,7 @@ func TestFuncDecl(t *testing.T) {
 			}
 
 			c := NewCompiler(&pkg.Info, nil)
+			c.Options = options
 
 			goFuncDecl := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
 			pyFuncDecl := c.compileFuncDecl(goFuncDecl)
@@ -239,3 +506,37 @@ func TestFuncDecl(t *testing.T) {
 		})
 	}
 }
+
+func TestFuncDecl(t *testing.T) {
+	runFuncDeclTests(t, funcDeclTests, Options{})
+}
+
+var underscoreUnexportedFuncDeclTests = []funcDeclTest{
+	{"func (t *T) f() { t.x = t.y }", FuncDecl{T.Id, &py.FunctionDef{
+		Name: py.Identifier("_f"),
+		Args: py.Arguments{Args: []py.Arg{{Arg: py.Identifier("t")}}},
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: py.Identifier("t")}, Attr: py.Identifier("_x")}},
+				Value:   &py.Attribute{Value: &py.Name{Id: py.Identifier("t")}, Attr: py.Identifier("_y")},
+			},
+		},
+	}}},
+	// Local variables with the same names as fields do not affect the field names
+	{"func (t T) F() { x := t.x; t.F(); _ = x }", FuncDecl{T.Id, &py.FunctionDef{
+		Name: py.Identifier("F"),
+		Args: py.Arguments{Args: []py.Arg{{Arg: py.Identifier("t")}}},
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{x},
+				Value:   &py.Attribute{Value: &py.Name{Id: py.Identifier("t")}, Attr: py.Identifier("_x")},
+			},
+			&py.ExprStmt{Value: &py.Call{Func: &py.Attribute{Value: &py.Name{Id: py.Identifier("t")}, Attr: py.Identifier("F")}}},
+			&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}}, Value: x},
+		},
+	}}},
+}
+
+func TestUnderscoreUnexportedFuncDecl(t *testing.T) {
+	runFuncDeclTests(t, underscoreUnexportedFuncDeclTests, Options{UnderscoreUnexported: true})
+}
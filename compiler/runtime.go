@@ -1,7 +1,153 @@
 package compiler
 
-import py "github.com/mbergin/gotopython/pythonast"
+import (
+	_ "embed"
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/types"
+)
+
+// Runtime is the source of the Python module runtime, which the compiled
+// modules import for panics, deferred calls, goroutines, channels,
+// formatting and parsing.
+//
+//go:embed runtime.py
+var Runtime string
 
 var (
 	runtimeModule = &py.Name{Id: py.Identifier("runtime")}
+
+	// go calls a function in a new thread
+	goStart = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("go")}
+
+	// GoPanic is the exception raised by panic. It is a context manager that
+	// recover returns the value of while it is active.
+	goPanic     = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("GoPanic")}
+	goRecover   = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("recover")}
+	pyRecovered = py.Identifier("recovered")
+
+	// asPanic returns the GoPanic of an exception that Python raised
+	goAsPanic = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("asPanic")}
+
+	// formatValue, quote and typeName format values as the %v, %q and %T
+	// verbs do
+	goFormatValue = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("formatValue")}
+	goQuote       = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("quote")}
+	goTypeName    = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("typeName")}
+
+	// sprint formats operands as fmt.Sprint does when their types are only
+	// known at run time
+	goSprint = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("sprint")}
+
+	// sprintf formats operands as fmt.Sprintf does when the format or the
+	// number of operands is only known at run time
+	goSprintf = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("sprintf")}
+
+	// parseInt and parseUint return an error for invalid input as
+	// strconv.ParseInt and strconv.ParseUint do
+	goParseInt  = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("parseInt")}
+	goParseUint = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("parseUint")}
+
+	// IntEnum is the base class of named integer types compiled to enums
+	goIntEnum = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("IntEnum")}
+
+	// Chan is a channel of a size with the zero value of its elements
+	goChan = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Chan")}
+
+	// runes yields the byte offset and code point of each rune of a string
+	goRunes = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("runes")}
+
+	// writableMap returns a map that is assigned to, panicking if it is nil
+	goWritableMap = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("writableMap")}
+
+	// rangeChan returns a channel that is ranged over, raising a deadlock
+	// error if it is nil
+	goRangeChan = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("rangeChan")}
 )
+
+// runtimePackages are the Go packages whose members the runtime module
+// provides, and which are compiled as it.
+var runtimePackages = map[string]bool{
+	"sync": true,
+}
+
+// runtimeMembers are the members of the packages that the runtime module
+// provides that it implements.
+var runtimeMembers = map[string]map[string]bool{
+	"runtime": {"GOOS": true, "GOARCH": true, "Gosched": true},
+	"sync":    {"Mutex": true, "WaitGroup": true},
+}
+
+// checkRuntimeMembers panics if files refer to a member of a package that
+// the runtime module provides but does not implement.
+func (c *Compiler) checkRuntimeMembers(files []*ast.File) {
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			sel, ok := node.(*ast.SelectorExpr)
+			if !ok || !c.isRuntimePackage(sel.X) {
+				return true
+			}
+			path := c.importedPackage(sel.X).Path()
+			if !runtimeMembers[path][sel.Sel.Name] {
+				panic(c.err(sel, "%s.%s is not supported", path, sel.Sel.Name))
+			}
+			return true
+		})
+	}
+}
+
+// isRuntimePackage reports whether expr names an imported package whose
+// members the runtime module provides. Go's runtime package shares its name.
+func (c *Compiler) isRuntimePackage(expr ast.Expr) bool {
+	pkg := c.importedPackage(expr)
+	return pkg != nil && (runtimePackages[pkg.Path()] || pkg.Path() == "runtime")
+}
+
+// usesRuntime reports whether the code compiled from files refers to the
+// runtime module.
+func (c *Compiler) usesRuntime(files []*ast.File) bool {
+	found := len(c.enums) > 0
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.DeferStmt, *ast.GoStmt:
+				found = true
+			case *ast.SelectStmt:
+				// A select without a default polls its cases and yields
+				found = found || !hasDefault(n)
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					if index, ok := lhs.(*ast.IndexExpr); ok && c.isMapWrite(index) {
+						found = true
+					}
+				}
+			case *ast.RangeStmt:
+				if isString(c.TypeOf(n.X)) && n.Key != nil && !c.isBlank(n.Key) {
+					found = true
+				}
+				if _, ok := c.TypeOf(n.X).Underlying().(*types.Chan); ok && c.mayBeNil(n.X) {
+					found = true
+				}
+			case *ast.IncDecStmt:
+				if index, ok := n.X.(*ast.IndexExpr); ok && c.isMapWrite(index) {
+					found = true
+				}
+			case *ast.SelectorExpr:
+				found = found || c.isRuntimePackage(n.X)
+			case *ast.CallExpr:
+				found = found || c.formatUsesRuntime(n) || c.strconvUsesRuntime(n)
+				if fun, ok := ast.Unparen(n.Fun).(*ast.Ident); ok {
+					switch c.ObjectOf(fun) {
+					case builtin.panic, builtin.recover:
+						found = true
+					case builtin.make:
+						_, isChan := c.TypeOf(n.Args[0]).Underlying().(*types.Chan)
+						found = found || isChan
+					}
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
@@ -0,0 +1,536 @@
+"""Runtime support for the Python modules compiled by gotopython.
+
+A compiled module imports this module as runtime when it panics, defers,
+starts goroutines, assigns to map entries, ranges over strings, parses
+integers, declares enums or formats values that Python's str formats
+differently. It also stands in for packages runtime
+and sync and implements channels.
+"""
+
+import collections
+import enum
+import os
+import platform
+import sys
+import threading
+import time
+import traceback
+import types
+
+_local = threading.local()
+
+
+def _panicking():
+    """Returns the panics of this thread whose deferred functions are being
+    called, innermost last."""
+    if not hasattr(_local, "panics"):
+        _local.panics = []
+    return _local.panics
+
+
+class GoPanic(Exception):
+    """GoPanic is raised by panic(value).
+
+    The deferred functions of a function that it unwinds are called inside
+    a with statement on the panic, so that recover can stop it.
+    """
+
+    def __init__(self, value):
+        super().__init__(value)
+        self.value = value
+        self.recovered = False
+
+    def __enter__(self):
+        _panicking().append(self)
+        return self
+
+    def __exit__(self, *exc_info):
+        _panicking().pop()
+        return False
+
+
+def recover():
+    """Stops the panic whose deferred functions are being called and returns
+    its value. Returns None if there is no panic or it has been recovered."""
+    panics = _panicking()
+    if not panics or panics[-1].recovered:
+        return None
+    panics[-1].recovered = True
+    return panics[-1].value
+
+
+def asPanic(e):
+    """Returns the panic that the exception e is. An exception that Python
+    raised, such as an IndexError, is a run-time panic whose value is e."""
+    return e if isinstance(e, GoPanic) else GoPanic(e)
+
+
+def _enumOp(op):
+    """Returns the method of an IntEnum that applies the int operator op and
+    gives a value of the same enum, as arithmetic on a named integer type
+    does."""
+
+    def method(self, *args):
+        result = op(self, *args)
+        if type(result) is not int:
+            return result
+        return type(self)(result)
+
+    return method
+
+
+class IntEnum(enum.IntEnum):
+    """IntEnum is the base class of the named integer types whose constants
+    are compiled to enum members. As with a Go integer, a value need not be
+    one of the constants."""
+
+    __add__ = _enumOp(int.__add__)
+    __radd__ = _enumOp(int.__radd__)
+    __sub__ = _enumOp(int.__sub__)
+    __rsub__ = _enumOp(int.__rsub__)
+    __mul__ = _enumOp(int.__mul__)
+    __rmul__ = _enumOp(int.__rmul__)
+    __floordiv__ = _enumOp(int.__floordiv__)
+    __rfloordiv__ = _enumOp(int.__rfloordiv__)
+    __mod__ = _enumOp(int.__mod__)
+    __rmod__ = _enumOp(int.__rmod__)
+    __and__ = _enumOp(int.__and__)
+    __rand__ = _enumOp(int.__rand__)
+    __or__ = _enumOp(int.__or__)
+    __ror__ = _enumOp(int.__ror__)
+    __xor__ = _enumOp(int.__xor__)
+    __rxor__ = _enumOp(int.__rxor__)
+    __lshift__ = _enumOp(int.__lshift__)
+    __rshift__ = _enumOp(int.__rshift__)
+    __neg__ = _enumOp(int.__neg__)
+    __invert__ = _enumOp(int.__invert__)
+
+    @classmethod
+    def _missing_(cls, value):
+        if not isinstance(value, int):
+            return None
+        member = int.__new__(cls, value)
+        member._name_ = str(value)
+        member._value_ = value
+        return member
+
+
+def runes(s):
+    """Yields the byte offset and code point of each rune of s, as ranging
+    over a string does."""
+    offset = 0
+    for ch in s:
+        yield offset, ord(ch)
+        offset += len(ch.encode("utf-8"))
+
+
+class Deadlock(Exception):
+    """Deadlock is raised by an operation that would block forever."""
+
+
+def rangeChan(ch):
+    """Returns ch, which is ranged over, or raises Deadlock if it is a nil
+    channel, which no value is ever received from."""
+    if ch is None:
+        raise Deadlock("range over nil channel blocks forever")
+    return ch
+
+
+def writableMap(m):
+    """Returns m, which an entry is assigned to, or panics if it is a nil
+    map."""
+    if m is None:
+        raise GoPanic("assignment to entry in nil map")
+    return m
+
+
+_escapes = {
+    "\a": "\\a",
+    "\b": "\\b",
+    "\f": "\\f",
+    "\n": "\\n",
+    "\r": "\\r",
+    "\t": "\\t",
+    "\v": "\\v",
+}
+
+
+def formatValue(value):
+    """Returns value as %v formats it where Python's str differs from Go."""
+    if value is None:
+        return "<nil>"
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    return str(value)
+
+
+def sprint(*args):
+    """Returns args formatted as fmt.Sprint does, with a space between
+    operands when neither is a string."""
+    out = []
+    for i, arg in enumerate(args):
+        if i > 0 and not isinstance(arg, str) and not isinstance(args[i - 1], str):
+            out.append(" ")
+        out.append(formatValue(arg))
+    return "".join(out)
+
+
+def sprintf(format, *args):
+    """Returns args formatted by the Go format string format, as
+    fmt.Sprintf does, translating each verb to Python's % formatting."""
+    out = []
+    n = 0
+    i = 0
+    while i < len(format):
+        ch = format[i]
+        i += 1
+        if ch != "%":
+            out.append(ch)
+            continue
+        spec = "%"
+        while i < len(format) and format[i] in "+-# 0123456789.*":
+            if format[i] != "*":
+                spec += format[i]
+            elif n < len(args) and isinstance(args[n], int):
+                spec += str(args[n])
+                n += 1
+            else:
+                out.append("%!(BADPREC)" if spec.endswith(".") else "%!(BADWIDTH)")
+                n += 1
+            i += 1
+        if i == len(format):
+            out.append("%!(NOVERB)")
+            break
+        verb = format[i]
+        i += 1
+        if verb == "%":
+            out.append("%")
+            continue
+        if n >= len(args):
+            out.append("%!" + verb + "(MISSING)")
+            continue
+        arg = args[n]
+        n += 1
+        if verb in "sq" and isinstance(arg, list):
+            # A byte slice is a list of ints
+            arg = bytes(arg).decode()
+        if verb in "vt":
+            verb, arg = "s", formatValue(arg)
+        elif verb == "q":
+            verb, arg = "s", quote(arg)
+        elif verb == "T":
+            verb, arg = "s", typeName(arg)
+        out.append((spec + verb) % (arg,))
+    return "".join(out)
+
+
+_typeNames = {bool: "bool", int: "int", float: "float64", complex: "complex128", str: "string"}
+
+
+def typeName(value):
+    """Returns the Go name of the type of value as %T formats it. A Python
+    type that several Go types compile to is named as the type that Go gives
+    an untyped constant, a pointer to a struct is named as the struct it
+    points to, and the elements of a slice or map give its element type."""
+    if value is None:
+        return "<nil>"
+    cls = type(value)
+    if cls in _typeNames:
+        return _typeNames[cls]
+    if isinstance(value, list):
+        return "[]" + _elemTypeName(value)
+    if isinstance(value, dict):
+        return "map[%s]%s" % (_elemTypeName(value.keys()), _elemTypeName(value.values()))
+    if isinstance(value, BaseException):
+        return "*errors.errorString"
+    if isinstance(value, (types.FunctionType, types.MethodType)):
+        # The signature of a function is not known at run time
+        return "func()"
+    return cls.__module__ + "." + cls.__name__
+
+
+def _elemTypeName(values):
+    """Returns the Go name of the type of the elements values, or interface {}
+    if they are of several types or there are none."""
+    names = {typeName(v) for v in values}
+    return names.pop() if len(names) == 1 else "interface {}"
+
+
+def quote(value):
+    """Returns a string double-quoted, or a rune single-quoted, with Go
+    escapes as %q formats it."""
+    if isinstance(value, int):
+        delim, text = "'", chr(value)
+    else:
+        delim, text = '"', value
+    out = [delim]
+    for ch in text:
+        if ch == delim or ch == "\\":
+            out.append("\\" + ch)
+        elif ch in _escapes:
+            out.append(_escapes[ch])
+        elif ch.isprintable():
+            out.append(ch)
+        elif ord(ch) < 0x80:
+            out.append("\\x%02x" % ord(ch))
+        elif ord(ch) < 0x10000:
+            out.append("\\u%04x" % ord(ch))
+        else:
+            out.append("\\U%08x" % ord(ch))
+    out.append(delim)
+    return "".join(out)
+
+
+class NumError:
+    """NumError is the error that parseInt and parseUint return, as
+    strconv.NumError is."""
+
+    def __init__(self, func, num, err):
+        self.Func = func
+        self.Num = num
+        self.Err = err
+
+    def Error(self):
+        return "strconv.%s: parsing %s: %s" % (self.Func, quote(self.Num), self.Err)
+
+
+_digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+_prefixBases = {"0b": 2, "0o": 8, "0x": 16}
+
+
+def _parse(func, s, base, bitSize, signed):
+    """Returns the integer that s represents in base and None, or an
+    error, as strconv.ParseInt and strconv.ParseUint do."""
+    if bitSize == 0:
+        bitSize = 64
+    if not 0 < bitSize <= 64:
+        return 0, NumError(func, s, "invalid bit size %d" % bitSize)
+    text = s
+    negative = False
+    if signed and text[:1] in ("+", "-"):
+        negative = text[0] == "-"
+        text = text[1:]
+    underscores = base == 0
+    if base == 0:
+        base = 10
+        prefixed = True
+        if text[:2].lower() in _prefixBases:
+            base = _prefixBases[text[:2].lower()]
+            text = text[2:]
+        elif text[:1] == "0" and len(text) > 1:
+            base = 8
+            text = text[1:]
+        else:
+            prefixed = False
+        # An underscore may separate the prefix from the digits
+        if prefixed and text.startswith("_"):
+            text = text[1:]
+    elif not 2 <= base <= 36:
+        return 0, NumError(func, s, "invalid base %d" % base)
+    valid = _digits[:base]
+    if not text or text.startswith("_") or text.endswith("_") or "__" in text:
+        return 0, NumError(func, s, "invalid syntax")
+    for ch in text.lower():
+        if ch not in valid and not (underscores and ch == "_"):
+            return 0, NumError(func, s, "invalid syntax")
+    value = int(text.replace("_", ""), base)
+    if negative:
+        value = -value
+    if signed:
+        low, high = -(1 << (bitSize - 1)), (1 << (bitSize - 1)) - 1
+    else:
+        low, high = 0, (1 << bitSize) - 1
+    if value > high:
+        return high, NumError(func, s, "value out of range")
+    if value < low:
+        return low, NumError(func, s, "value out of range")
+    return value, None
+
+
+def parseInt(s, base, bitSize):
+    return _parse("ParseInt", s, base, bitSize, True)
+
+
+def parseUint(s, base, bitSize):
+    return _parse("ParseUint", s, base, bitSize, False)
+
+
+def go(f, *args):
+    """Calls f(*args) in a new thread, as the go statement does.
+
+    The thread is a daemon so that the program exits when main returns.
+    A panic that the goroutine does not recover ends the program, as it
+    does in Go, rather than only the thread.
+    """
+
+    def run():
+        try:
+            f(*args)
+        except BaseException as e:
+            _crash(e)
+
+    threading.Thread(target=run, daemon=True).start()
+
+
+def _crash(e):
+    """Reports the unrecovered panic e and exits with Go's exit status."""
+    value = e.value if isinstance(e, GoPanic) else e
+    sys.stdout.flush()
+    print("panic: " + formatValue(value), file=sys.stderr)
+    traceback.print_exception(type(e), e, e.__traceback__, file=sys.stderr)
+    sys.stderr.flush()
+    os._exit(2)
+
+
+def _goos():
+    """Returns Go's name of the operating system, as runtime.GOOS is."""
+    for prefix, goos in (("win", "windows"), ("linux", "linux"), ("darwin", "darwin"),
+                         ("freebsd", "freebsd"), ("openbsd", "openbsd"), ("netbsd", "netbsd")):
+        if sys.platform.startswith(prefix):
+            return goos
+    return sys.platform
+
+
+def _goarch():
+    """Returns Go's name of the architecture, as runtime.GOARCH is."""
+    machine = platform.machine().lower()
+    return {
+        "x86_64": "amd64",
+        "amd64": "amd64",
+        "aarch64": "arm64",
+        "arm64": "arm64",
+        "i386": "386",
+        "i686": "386",
+        "x86": "386",
+        "armv6l": "arm",
+        "armv7l": "arm",
+    }.get(machine, machine)
+
+
+GOOS = _goos()
+GOARCH = _goarch()
+
+
+def Gosched():
+    """Lets other threads run."""
+    time.sleep(0)
+
+
+class WaitGroup:
+    """WaitGroup waits for a count of goroutines to finish, as
+    sync.WaitGroup does."""
+
+    def __init__(self):
+        self.count = 0
+        self.cond = threading.Condition()
+
+    def Add(self, delta):
+        with self.cond:
+            self.count += delta
+            if self.count < 0:
+                raise GoPanic("sync: negative WaitGroup counter")
+            if self.count == 0:
+                self.cond.notify_all()
+
+    def Done(self):
+        self.Add(-1)
+
+    def Wait(self):
+        with self.cond:
+            self.cond.wait_for(lambda: self.count == 0)
+
+
+class Mutex:
+    """Mutex is a lock that any thread can unlock, as sync.Mutex is."""
+
+    def __init__(self):
+        self.lock = threading.Lock()
+
+    def Lock(self):
+        self.lock.acquire()
+
+    def Unlock(self):
+        if not self.lock.locked():
+            raise GoPanic("sync: unlock of unlocked mutex")
+        self.lock.release()
+
+
+class Chan:
+    """Chan is a channel that buffers up to size values.
+
+    A send to an unbuffered channel waits for a receiver to take the value.
+    Receives from a closed and drained channel return zero.
+    """
+
+    def __init__(self, size=0, zero=None):
+        self.size = size
+        self.zero = zero
+        self.values = collections.deque()
+        self.sent = 0
+        self.received = 0
+        self.receivers = 0
+        self.closed = False
+        self.cond = threading.Condition()
+
+    def __len__(self):
+        with self.cond:
+            return min(len(self.values), self.size)
+
+    def send(self, value):
+        with self.cond:
+            self._put(value)
+            n = self.sent
+            self.cond.wait_for(
+                lambda: self.received >= n - self.size or self.closed)
+
+    def trySend(self, value):
+        """Sends value if it would not wait and reports whether it did."""
+        with self.cond:
+            if len(self.values) >= self.size + self.receivers:
+                return False
+            self._put(value)
+            return True
+
+    def _put(self, value):
+        if self.closed:
+            raise GoPanic("send on closed channel")
+        self.values.append(value)
+        self.sent += 1
+        self.cond.notify_all()
+
+    def recvOk(self):
+        """Returns the next value and True, or zero and False if the
+        channel is closed and drained."""
+        with self.cond:
+            self.receivers += 1
+            self.cond.notify_all()
+            self.cond.wait_for(lambda: self.values or self.closed)
+            self.receivers -= 1
+            if not self.values:
+                return self.zero, False
+            self.received += 1
+            self.cond.notify_all()
+            return self.values.popleft(), True
+
+    def recv(self):
+        return self.recvOk()[0]
+
+    def tryRecv(self):
+        """Returns what recvOk would if it would not wait, or else an empty
+        tuple."""
+        with self.cond:
+            if self.values:
+                self.received += 1
+                self.cond.notify_all()
+                return self.values.popleft(), True
+            if self.closed:
+                return self.zero, False
+            return ()
+
+    def close(self):
+        with self.cond:
+            if self.closed:
+                raise GoPanic("close of closed channel")
+            self.closed = True
+            self.cond.notify_all()
@@ -0,0 +1,2060 @@
+package compiler
+
+import (
+	"bytes"
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+)
+
+// runPython runs script against golang compiled to the module main and returns its output.
+func runPython(t *testing.T, golang string, script string, options Options) (stdout, stderr string, err error) {
+	pkg, file, errs := buildFile(golang)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	c := NewCompiler(&pkg.Info, nil)
+	c.Options = options
+	return runModule(t, c.CompileFiles([]*ast.File{file}), script)
+}
+
+// runModule writes module as main.py and runs script after importing it.
+func runModule(t *testing.T, module *py.Module, script string) (stdout, stderr string, err error) {
+	python, lookErr := exec.LookPath("python3")
+	if lookErr != nil {
+		t.Skip("python3 is not installed")
+	}
+	dir, tempErr := ioutil.TempDir("", "gotopython")
+	if tempErr != nil {
+		t.Fatal(tempErr)
+	}
+	defer os.RemoveAll(dir)
+	var code bytes.Buffer
+	py.NewWriter(&code).WriteModule(module)
+	files := map[string]string{"main.py": code.String(), "runtime.py": Runtime}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out, errOut bytes.Buffer
+	cmd := exec.Command(python, "-B", "-c", "import main\n"+script)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+var runtimeTests = []struct {
+	golang string
+	script string
+	want   string
+}{
+	// The map and key of a comma-ok index are evaluated once
+	{`package main
+
+type counter struct{ calls int }
+
+func (c *counter) get() map[string]int {
+	c.calls++
+	return map[string]int{"a": 1}
+}
+
+func (c *counter) key() string {
+	c.calls++
+	return "a"
+}
+
+func f(c *counter) (int, bool) {
+	v, ok := c.get()[c.key()]
+	return v, ok
+}
+`, "c = main.counter()\nprint(main.f(c), c.calls)", "(1, True) 2\n"},
+	// Bools, nil, quoted strings and byte slices are formatted as Go formats them
+	{`package main
+
+import "fmt"
+
+func f() string {
+	var p *int
+	var o interface{}
+	var v interface{} = true
+	fmt.Println(true, o, p, 1)
+	return fmt.Sprintf("%t %v %v %v %v %q %q", false, true, p, o, v, "a\"b\n", []byte{104, 105})
+}
+`, "print(main.f())", "true <nil> <nil> 1\n" + `false true <nil> <nil> true "a\"b\n" "hi"` + "\n"},
+	// A printf-style wrapper forwards its format and arguments, which are
+	// formatted at run time
+	{`package main
+
+import "fmt"
+
+type logger struct{ lines []string }
+
+func (l *logger) Logf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Printf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func f() []string {
+	l := &logger{[]string{}}
+	l.Logf("%d items, ok=%v, name=%q, %5.1f%%", 3, true, "x", 2.25)
+	l.Logf("%-4s|%*d|%T", "ab", 3, 7, l)
+	l.Logf("none")
+	l.Printf("%s=%t", "done", false)
+	return l.lines
+}
+`, "print(main.f())", "done=false\n['3 items, ok=true, name=\"x\",   2.2%', 'ab  |  7|main.logger', 'none']\n"},
+	// A format at run time reports missing arguments and verbs as Go does,
+	// and %T names the same types at run time as when it is compiled
+	{`package main
+
+import "fmt"
+
+type Point struct{ x, y int }
+
+func sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(format, args...)
+}
+
+func f() []string {
+	p := Point{1, 2}
+	var v interface{} = p
+	return []string{
+		fmt.Sprintf("%T %T %T %T %T %T", 1, "a", 1.5, p, v, []int{1}),
+		sprintf("%T %T %T %T %T %T", 1, "a", 1.5, p, v, []int{1}),
+		sprintf("%d %d", 1),
+		sprintf("100%"),
+		sprintf("%*d|%T", "x", 1, nil),
+	}
+}
+`, "print(*main.f(), sep='\\n')", "int string float64 main.Point main.Point []int\nint string float64 main.Point main.Point []int\n1 %!d(MISSING)\n100%!(NOVERB)\n%!(BADWIDTH)1|<nil>\n"},
+	// A method declared on the outer struct shadows the method promoted
+	// from its embedded struct, which is still called through the field
+	{`package main
+
+type base struct{ name string }
+
+func (b base) Name() string  { return b.name }
+func (b base) Greet() string { return "hi " + b.name }
+
+type admin struct {
+	base
+	level int
+}
+
+func (a admin) Name() string { return "admin " + a.base.Name() }
+
+type namer interface{ Name() string }
+
+func f() (string, string, string) {
+	a := admin{base{"ann"}, 1}
+	var n namer = a
+	return a.Name(), n.Name(), a.Greet()
+}
+`, "print(main.f())", "('admin ann', 'admin ann', 'hi ann')\n"},
+	// A named map type is a dict with the methods declared on the type
+	{`package main
+
+type StringSet map[string]bool
+
+func (s StringSet) Add(v string) { s[v] = true }
+func (s StringSet) Has(v string) bool { return s[v] }
+
+func f() (bool, bool, int, bool) {
+	s := make(StringSet)
+	s.Add("a")
+	t := StringSet{"b": true}
+	t.Add("c")
+	return s.Has("a"), s.Has("b"), len(t), t.Has("c")
+}
+`, "print(main.f())", "(True, False, 2, True)\n"},
+	// The methods of a named map can be called on a nil map
+	{`package main
+
+type StringSet map[string]bool
+
+func (s StringSet) Has(v string) bool { return s[v] }
+
+type index struct{ seen StringSet }
+
+func f() (bool, bool) {
+	var s StringSet
+	x := index{}
+	return s.Has("a"), x.seen.Has("a")
+}
+`, "print(main.f())", "(False, False)\n"},
+	// An interface is an abstract base class that cannot be instantiated
+	{`package main
+
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+}
+
+type square struct{ side float64 }
+
+func (s square) Area() float64      { return s.side * s.side }
+func (s square) Perimeter() float64 { return 4 * s.side }
+
+func total(shapes []Shape) float64 {
+	sum := 0.0
+	for _, s := range shapes {
+		sum += s.Area() + s.Perimeter()
+	}
+	return sum
+}
+`, `
+import abc
+print(issubclass(main.Shape, abc.ABC), sorted(main.Shape.__abstractmethods__))
+try:
+    main.Shape()
+except TypeError:
+    print("abstract")
+print(main.total([main.square(1.0), main.square(2.0)]))
+`, "True ['Area', 'Perimeter']\nabstract\n17.0\n"},
+	// With no target configured, runtime.GOOS and runtime.GOARCH are those
+	// of the machine running the Python module
+	{`package main
+
+import "runtime"
+
+func f() string { return runtime.GOOS + "/" + runtime.GOARCH }
+`, "print(main.f())", goruntime.GOOS + "/" + goruntime.GOARCH + "\n"},
+	// Instances of a struct do not share the zero values of their array
+	// and struct fields
+	{`package main
+
+type inner struct{ n int }
+
+type grid struct {
+	cells [3]int
+	pos   inner
+	inner inner
+}
+
+func f() ([3]int, [3]int, int, int) {
+	var a, b grid
+	a.cells[0] = 1
+	a.pos.n = 2
+	a.inner.n = 4
+	c := grid{}
+	c.cells[1] = 3
+	return a.cells, b.cells, a.pos.n + a.inner.n, b.pos.n + b.inner.n
+}
+`, "print(main.f())", "([1, 0, 0], [0, 0, 0], 6, 0)\n"},
+	// Variadic functions and fmt.Println can be called without variadic
+	// arguments
+	{`package main
+
+import "fmt"
+
+func sum(base int, xs ...int) int {
+	for _, x := range xs {
+		base += x
+	}
+	return base
+}
+
+func count(xs ...interface{}) int { return len(xs) }
+
+func f() (int, int, int, int) {
+	fmt.Println()
+	fmt.Print()
+	var none []interface{}
+	return sum(1), count(), sum(1, 2, 3), count(none...)
+}
+`, "print(main.f())", "\n(1, 0, 6, 0)\n"},
+	// The elements of a variadic parameter can be assigned to, and it can
+	// be appended to
+	{`package main
+
+func double(xs ...int) []int {
+	for i := range xs {
+		xs[i] *= 2
+	}
+	return xs
+}
+
+func rotate(xs ...int) []int {
+	xs = append(xs, xs[0])
+	return xs[1:]
+}
+
+func f() ([]int, []int, []int) {
+	return double(1, 2), rotate(1, 2, 3), append([]int{9}, double(4)...)
+}
+`, "print(main.f())", "([2, 4], [2, 3, 1], [9, 8])\n"},
+	// An embedded struct is a base class whose fields and methods the outer
+	// struct inherits
+	{`package main
+
+type Labeled struct {
+	Base
+	label string
+}
+
+func (l *Labeled) Describe() string { return l.label + ":" + l.Base.Describe() }
+
+type Base struct {
+	id   int
+	name string
+}
+
+func (b *Base) Describe() string { return b.name }
+func (b *Base) Rename(name string) { b.name = name }
+func (b Base) ID() int            { return b.id }
+
+func f() (string, string, int, bool) {
+	base := Base{1, "a"}
+	l := &Labeled{Base: base, label: "x"}
+	l.Rename("b")
+	l.id += 10
+	other := Labeled{Base{2, "c"}, "y"}
+	return l.Describe(), other.Base.Describe(), l.ID() + other.id, base.name == "a"
+}
+`, `
+print(issubclass(main.Labeled, main.Base))
+print(main.f())
+`, "True\n('x:b', 'c', 13, True)\n"},
+	// The embedded struct of a literal is evaluated where the literal is,
+	// and selecting an inherited struct copies it unless its address is
+	// taken
+	{`package main
+
+type Base struct{ id int }
+
+func (b *Base) Bump() { b.id++ }
+
+type Item struct {
+	Base
+	name string
+}
+
+type factory struct{ made int }
+
+func (m *factory) newBase() Base {
+	m.made++
+	return Base{m.made * 10}
+}
+
+func (m *factory) pick(ok bool) int {
+	if ok && (Item{Base: m.newBase(), name: "x"}).id > 0 {
+		return m.made
+	}
+	return -1
+}
+
+func f() (int, int, int, int, string) {
+	m := &factory{}
+	none, one := m.pick(false), m.pick(true)
+	it := Item{Base{1}, "a"}
+	b := it.Base
+	b.Bump()
+	p := &it.Base
+	p.Bump()
+	return none, one, b.id, it.id, it.name
+}
+`, `
+print(main.f())
+`, "(-1, 1, 2, 2, 'a')\n"},
+	// A named function type is an alias of typing.Callable, and may refer
+	// to an alias declared after it
+	{`package main
+
+type Middleware func(Handler) Handler
+
+type Handler func(int) error
+
+type Server struct{ handle Handler }
+
+type codeError struct{ code int }
+
+func (e *codeError) Error() string { return "error" }
+
+func (s *Server) serve(code int) bool {
+	return s.handle(code) == nil
+}
+
+func f() (bool, bool) {
+	s := &Server{handle: func(code int) error {
+		if code >= 400 {
+			return &codeError{code}
+		}
+		return nil
+	}}
+	return s.serve(200), s.serve(404)
+}
+`, `
+import typing
+print(main.Handler == typing.Callable[[int], Exception])
+print(main.f())
+`, "True\n(True, False)\n"},
+	// A function type with methods is a class whose instances call the
+	// function they are converted from, and whose methods can be called on
+	// functions assigned to it without a conversion
+	{`package main
+
+type Handler interface{ Serve(n int) string }
+
+type HandlerFunc func(n int) string
+
+func (f HandlerFunc) Serve(n int) string { return f(n) }
+
+type Twice func(int) int
+
+func (t Twice) Apply(n int) int { return t(t(n)) }
+
+func f() (string, string, int, int) {
+	var h Handler = HandlerFunc(func(n int) string { return "served " + string(rune('0'+n)) })
+	var direct HandlerFunc = func(n int) string { return "direct" }
+	inc := Twice(func(n int) int { return n + 1 })
+	var raw Twice = func(n int) int { return n * 3 }
+	return h.Serve(1), direct.Serve(2), inc.Apply(1), raw.Apply(2)
+}
+`, "print(main.f(), main.HandlerFunc(lambda n: n)(4))", "('served 1', 'direct', 3, 18) 4\n"},
+	// An interface case of a type switch matches every type that
+	// implements the interface, not only the interface's own class, and a
+	// nil case matches nil
+	{`package main
+
+type Shape interface{ Area() float64 }
+
+type Square struct{ side float64 }
+
+func (q Square) Area() float64 { return q.side * q.side }
+
+type Circle struct{ r float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.r * c.r }
+
+func kind(v interface{}) string {
+	switch s := v.(type) {
+	case Shape:
+		if s.Area() > 5 {
+			return "big shape"
+		}
+		return "shape"
+	case int:
+		return "int"
+	case nil:
+		return "nil"
+	default:
+		return "other"
+	}
+}
+
+func f() (string, string, string, string, string) {
+	return kind(Square{2}), kind(&Circle{2}), kind(3), kind("a"), kind(nil)
+}
+`, `
+print(main.f())
+`, "('shape', 'big shape', 'int', 'other', 'nil')\n"},
+	// Aliases of interfaces, pointers and function types are typing.Any,
+	// the class pointed to and typing.Callable
+	{`package main
+
+type Point struct{ x int }
+
+type Value = any
+
+type Ref = *Point
+
+type Scale = func(int) int
+
+func f() (int, int) {
+	var r Ref = &Point{2}
+	var by Scale = func(n int) int { return n * r.x }
+	var v Value = 3
+	return by(v.(int)), r.x
+}
+`, `
+import typing
+print(main.f(), main.Value is typing.Any, main.Ref is main.Point, main.Scale == typing.Callable[[int], int])
+`, "(6, 2) True True True\n"},
+	// Map values whose literals elide their type take it from the map type
+	{`package main
+
+type point struct{ x, y int }
+
+func f() ([]int, int, int, int) {
+	slices := map[string][]int{"a": {1, 2}, "b": {}}
+	points := map[string]point{"p": {1, 2}}
+	ptrs := map[string]*point{"q": {y: 3}}
+	grid := map[point][]point{{0, 0}: {{1, 1}, {2, 2}}}
+	return slices["a"], len(slices["b"]), points["p"].y+ptrs["q"].y, grid[point{}][1].x
+}
+`, "print(main.f())", "([1, 2], 0, 5, 2)\n"},
+	// A comma-ok assertion to a pointer type gives the pointer the interface
+	// holds, or nil and false
+	{`package main
+
+type node struct{ n int }
+type leaf struct{ n int }
+
+func find(v interface{}) (int, bool) {
+	if p, ok := v.(*node); ok {
+		p.n++
+		return p.n, true
+	}
+	p, ok := v.(*node)
+	return len(fmt(p)), ok
+}
+
+func fmt(p *node) string {
+	if p == nil {
+		return "nil"
+	}
+	return "node"
+}
+
+func f() (int, bool, int, bool, int) {
+	nd := &node{1}
+	a, aok := find(nd)
+	b, bok := find(&leaf{5})
+	return a, aok, b, bok, nd.n
+}
+`, "print(main.f())", "(2, True, 3, False, 2)\n"},
+	// complex builds a complex number from variables, and real and imag
+	// extract its parts
+	{`package main
+
+func f(re, im float64) (float64, float64, float64) {
+	z := complex(re, im)
+	w := z * 2i
+	return real(z), imag(z), real(w)
+}
+
+func g() complex128 {
+	var z complex64
+	return complex128(z) + 1i
+}
+`, "print(main.f(1.5, 2.0), main.g())", "(1.5, 2.0, -4.0) 1j\n"},
+	// Ranging over a closed channel receives the values sent before it was
+	// closed, and then receives are the zero value and not ok
+	{`package main
+
+func produce(ch chan int) {
+	defer close(ch)
+	for i := 1; i <= 3; i++ {
+		ch <- i
+	}
+}
+
+func f() (int, int, bool) {
+	ch := make(chan int, 3)
+	produce(ch)
+	sum := 0
+	for v := range ch {
+		sum += v
+	}
+	v, ok := <-ch
+	return sum, v, ok
+}
+
+func g() (n int) {
+	ch := make(chan string)
+	close(ch)
+	defer func() { n = len(recover().(string)) }()
+	close(ch)
+	return 0
+}
+`, "print(main.f(), main.g())", "(6, 0, False) 23\n"},
+	// A struct embedding an interface has its methods, which call the
+	// implementation it holds, and so implements the interface
+	{`package main
+
+type Writer interface{ Write(s string) int }
+
+type counter struct{ n int }
+
+func (c *counter) Write(s string) int {
+	c.n += len(s)
+	return len(s)
+}
+
+type logger struct {
+	Writer
+	prefix string
+}
+
+func (l logger) Log(s string) int { return l.Write(l.prefix + s) }
+
+func f() (int, int, int) {
+	c := &counter{}
+	l := logger{c, "> "}
+	var w Writer = l
+	return l.Log("hi"), w.Write("abc"), c.n
+}
+`, "print(main.f())", "(4, 3, 7)\n"},
+	// Converting between struct types with identical fields constructs the
+	// other type from a copy of the fields
+	{`package main
+
+type Celsius struct{ deg, min int }
+type Fahrenheit struct{ deg, min int }
+
+func (f Fahrenheit) String() string { return "F" }
+
+func f() (string, int, int) {
+	c := Celsius{20, 30}
+	f := Fahrenheit(c)
+	c.deg = 0
+	return f.String(), f.deg, f.min
+}
+`, "print(main.f())", "('F', 20, 30)\n"},
+	// copy copies as many elements as the shorter of its operands has, into
+	// a slice of a slice too
+	{`package main
+
+func f() (int, int, int, int, int, []int, []int, []int, []byte) {
+	a, b := []int{1, 2}, []int{0, 0}
+	short, long := []int{1, 2}, []int{0, 0, 0}
+	xs := []int{1, 2, 3, 4}
+	bs := make([]byte, 2)
+	n1 := copy(b, a)
+	n2 := copy(long, short)
+	n3 := copy(short, []int{7, 8, 9})
+	n4 := copy(xs[1:], xs)
+	n5 := copy(bs, "hey")
+	return n1, n2, n3, n4, n5, b, long, xs, bs
+}
+`, "print(main.f()[:5])\nprint(*main.f()[5:])", "(2, 2, 2, 3, 2)\n[1, 2] [1, 2, 0] [1, 1, 2, 3] [104, 101]\n"},
+	// Copying to or from a nil slice copies nothing
+	{`package main
+
+func f() (int, int, int, []int) {
+	var none []int
+	xs := []int{1, 2}
+	return copy(none, xs), copy(xs, none), copy(none[0:], none), xs
+}
+`, "print(main.f())", "(0, 0, 0, [1, 2])\n"},
+	// Slices of a string are strings that the strings functions operate on,
+	// and joining a nil slice gives an empty string
+	{`package main
+
+import "strings"
+
+func f(s string) (string, bool, bool, int, string, string) {
+	sub := s[2:5]
+	var none []string
+	return strings.ToUpper(sub), strings.HasPrefix(s[1:], "el"), strings.Contains(sub, "z"),
+		strings.Index(s, sub), strings.Join([]string{sub, strings.Repeat("!", 2)}, "-"), strings.Join(none, ",")
+}
+`, "print(main.f('hello'))", "('LLO', True, False, 2, 'llo-!!', '')\n"},
+	// append returns a new slice with one, several or spread elements, and
+	// appends the bytes of a spread string to a byte slice
+	{`package main
+
+func f() ([]int, []int, []byte) {
+	xs := []int{1}
+	ys := append(xs, 2)
+	ys = append(ys, 3, 4)
+	ys = append(ys, xs...)
+	bs := append([]byte("a"), "bc"...)
+	return xs, ys, bs
+}
+`, "print(main.f())", "([1], [1, 2, 3, 4, 1], [97, 98, 99])\n"},
+	// Indexing a string gives a byte, which compares equal to a rune literal
+	{`package main
+
+func count(s string) (int, int) {
+	ls, vowels := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'l' {
+			ls++
+		}
+		switch s[i] {
+		case 'a', 'e', 'i', 'o', 'u':
+			vowels++
+		}
+	}
+	return ls, vowels
+}
+`, "print(main.count('hello, world'), main.count('h\\u00e9llo'))", "(3, 3) (2, 1)\n"},
+	// A nil slice can be appended to and spread
+	{`package main
+
+type bag struct{ items []string }
+
+func f() ([]int, []int, []string) {
+	var xs []int
+	xs = append(xs, 1)
+	var none []int
+	ys := append([]int{0}, none...)
+	var b bag
+	b.items = append(b.items, "a")
+	return xs, ys, b.items
+}
+`, "print(main.f())", "([1], [0], ['a'])\n"},
+	// Flags, width and precision format as in Go
+	{`package main
+
+import "fmt"
+
+func f(x float64, n int, s string) string {
+	return fmt.Sprintf("%.2f|%05d|%-10s|%6.2f|%*d|%+d|%8t|", x, n, s, x, 4, n, n, true)
+}
+`, "print(main.f(3.14159, 42, 'go'))", "3.14|00042|go        |  3.14|  42|+42|    true|\n"},
+	// Sprint spaces operands when neither is a string, Sprintln always
+	{`package main
+
+import "fmt"
+
+type name string
+
+func f() (string, string, string) {
+	var s interface{} = "s"
+	var n interface{} = 2
+	return fmt.Sprint(1, 2, "a", 3, name("b"), true), fmt.Sprint(s, 1, n, nil), fmt.Sprintln("a", 1, false)
+}
+`, "print(main.f())", "('1 2a3btrue', 's1 2 <nil>', 'a 1 false\\n')\n"},
+	// A chain of calls to pointer-receiver methods builds one object
+	{`package main
+
+type builder struct{ items []int }
+
+func (b *builder) add(x int) *builder {
+	b.items = append(b.items, x)
+	return b
+}
+
+func (b *builder) build() int {
+	sum := 0
+	for _, x := range b.items {
+		sum += x
+	}
+	return sum
+}
+
+func f() (int, int, bool) {
+	b := &builder{items: []int{}}
+	c := b.add(1).add(2)
+	return b.add(3).build(), len(c.items), b == c
+}
+`, "print(main.f())", "(6, 3, True)\n"},
+	// A chain of calls to value-receiver methods builds copies and leaves
+	// the original unchanged
+	{`package main
+
+type point struct{ x, y int }
+
+func (p point) withX(x int) point {
+	p.x = x
+	return p
+}
+
+func (p point) withY(y int) point {
+	p.y = y
+	return p
+}
+
+func f() (int, int, int, int) {
+	p := point{1, 2}
+	q := p.withX(3).withY(4)
+	return p.x, p.y, q.x, q.y
+}
+`, "print(main.f())", "(1, 2, 3, 4)\n"},
+	// A value receiver's nested structs and arrays are copies too, and its
+	// slices are shared
+	{`package main
+
+import "fmt"
+
+type inner struct{ x int }
+
+type T struct {
+	inner inner
+	arr   [2]int
+	grid  [2][2]int
+	xs    []int
+}
+
+func (t T) setInner() { t.inner.x = 5 }
+func (t T) setArr()   { t.arr[0] = 7 }
+func (t T) setGrid()  { t.grid[1][0] = 8 }
+func (t T) setXs()    { t.xs[0] = 9 }
+
+func main() {
+	t := T{xs: []int{0}}
+	t.setInner()
+	t.setArr()
+	t.setGrid()
+	t.setXs()
+	fmt.Println(t.inner.x, t.arr[0], t.grid[1][0], t.xs[0])
+}
+`, "main.main()", "0 0 0 9\n"},
+	// A loop over a map can delete its entries
+	{`package main
+
+func f() (int, int, int) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+	for k, v := range m {
+		if v%2 == 1 {
+			delete(m, k)
+		}
+	}
+	left, two := len(m), m["b"]
+	for k := range m {
+		delete(m, k)
+	}
+	return left, two, len(m)
+}
+`, "print(main.f())", "(1, 2, 0)\n"},
+	// Ranging over a map gives its keys and values, and over a nil map nothing
+	{`package main
+
+func f(m map[string]int) (int, int, int, int) {
+	keys, sum, values := 0, 0, 0
+	for k, v := range m {
+		keys += len(k)
+		sum += v
+	}
+	for _, v := range m {
+		values += v
+	}
+	var nilMap map[string]int
+	for range nilMap {
+		keys = -1
+	}
+	for k := range m {
+		keys += len(k)
+	}
+	return keys, sum, values, len(m)
+}
+`, "print(main.f({'a': 1, 'bc': 2}))", "(6, 3, 3, 2)\n"},
+	// Ranging over an integer counts from zero up to it
+	{`package main
+
+func f(n int) (int, int) {
+	sum, count := 0, 0
+	for i := range n {
+		sum += i
+	}
+	for range 3 {
+		count++
+	}
+	return sum, count
+}
+`, "print(main.f(5))", "(10, 3)\n"},
+	// Ranging over a channel receives each value until it is closed, and
+	// ranging over a nil channel, which would block forever, is a deadlock
+	{`package main
+
+func newChan() chan int { return make(chan int, 2) }
+
+func sum(ch chan int) int {
+	total := 0
+	for v := range ch {
+		total += v
+	}
+	return total
+}
+`, "ch = main.newChan()\nch.send(1)\nch.send(2)\nch.close()\nprint(main.sum(ch), ch.recvOk())\n" +
+		"import runtime\ntry:\n    main.sum(None)\nexcept runtime.Deadlock as e:\n    print(e)",
+		"3 (0, False)\nrange over nil channel blocks forever\n"},
+	// Ranging over a string gives the byte offset and code point of each rune
+	{`package main
+
+func f() ([]int, []rune, int) {
+	offsets := []int{}
+	runes := []rune{}
+	for i, r := range "héllo, 世界" {
+		offsets = append(offsets, i)
+		runes = append(runes, r)
+	}
+	n := 0
+	for range "世界" {
+		n++
+	}
+	return offsets, runes, n
+}
+`, "print(main.f())", "([0, 1, 3, 4, 5, 6, 7, 8, 11], [104, 233, 108, 108, 111, 44, 32, 19990, 30028], 2)\n"},
+	// A uintptr is an int
+	{`package main
+
+func f(xs []int) (uintptr, int) {
+	n := uintptr(len(xs))
+	return n * 2, int(n) + xs[0]
+}
+`, "print(main.f([5, 6, 7]))", "(6, 8)\n"},
+	// continue advances the counter of a for loop
+	{`package main
+
+func f() (int, int) {
+	odd, i := 0, 0
+	for i = 0; i < 10; i++ {
+		if i%2 == 0 {
+			continue
+		}
+		odd++
+	}
+	return odd, i
+}
+`, "print(main.f())", "(5, 10)\n"},
+	// break and continue in a range over an integer, and a labeled break
+	// out of one
+	{`package main
+
+func f(n int) (int, int, int) {
+	last, odd, pairs := 0, 0, 0
+	for i := range n {
+		if i == 3 {
+			break
+		}
+		last = i
+	}
+	for i := range n {
+		if i%2 == 0 {
+			continue
+		}
+		odd++
+	}
+Outer:
+	for i := range n {
+		for j := range n {
+			if i+j == 3 {
+				break Outer
+			}
+			pairs++
+		}
+	}
+	return last, odd, pairs
+}
+`, "print(main.f(10))", "(2, 5, 3)\n"},
+	// A select with a default case polls its receive cases once, and a
+	// select without one waits until a case is ready
+	{`package main
+
+func poll(ch chan int) (int, bool) {
+	select {
+	case v := <-ch:
+		return v, true
+	default:
+		return -1, false
+	}
+}
+
+func wait(a, b chan int) string {
+	select {
+	case v := <-a:
+		return "a" + string(rune('0'+v))
+	case v, ok := <-b:
+		if !ok {
+			return "b closed"
+		}
+		return "b" + string(rune('0'+v))
+	}
+}
+`, `
+import runtime, threading
+ch = runtime.Chan(1, 0)
+print(main.poll(ch))
+ch.send(5)
+print(main.poll(ch))
+a, b = runtime.Chan(0, 0), runtime.Chan(0, 0)
+threading.Timer(0.05, lambda: b.send(7)).start()
+print(main.wait(a, b))
+b.close()
+print(main.wait(a, b))
+`, "(-1, False)\n(5, True)\nb7\nb closed\n"},
+	// A send case with a default sends only if the channel has room, and
+	// yields to other goroutines when it does not
+	{`package main
+
+import "runtime"
+
+func offer(ch chan int, v int) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+		runtime.Gosched()
+		return false
+	}
+}
+
+func f() (bool, bool, int, int) {
+	ch := make(chan int, 1)
+	first := offer(ch, 1)
+	second := offer(ch, 2)
+	return first, second, <-ch, len(ch)
+}
+`, "print(main.f())", "(True, False, 1, 0)\n"},
+	// A nil channel is never ready in a select
+	{`package main
+
+func f() (string, string) {
+	var a, b chan int
+	ready := make(chan int, 1)
+	ready <- 1
+	var first, second string
+	select {
+	case <-a:
+		first = "a"
+	case b <- 1:
+		first = "b"
+	default:
+		first = "default"
+	}
+	select {
+	case v := <-a:
+		second = string(rune('0' + v))
+	case v := <-ready:
+		second = "ready" + string(rune('0'+v))
+	}
+	return first, second
+}
+`, "print(main.f())", "('default', 'ready1')\n"},
+	// A break inside a select in a loop leaves the select and a continue
+	// continues the loop
+	{`package main
+
+func drain(ch chan int, done chan bool) (int, int) {
+	sum, evens := 0, 0
+	for i := 0; i < 5; i++ {
+		select {
+		case v := <-ch:
+			if v%2 == 0 {
+				evens++
+				continue
+			}
+			if v > 5 {
+				break
+			}
+			sum += v
+		case <-done:
+			return sum, evens
+		}
+		sum += 100
+	}
+	return sum, evens
+}
+
+func f() (int, int) {
+	ch := make(chan int, 10)
+	for _, v := range []int{1, 2, 3, 7, 4} {
+		ch <- v
+	}
+	return drain(ch, make(chan bool))
+}
+`, "print(main.f())", "(304, 2)\n"},
+	// break and continue to the outer of two and three nested loops
+	{`package main
+
+func pairs(n int) (int, int) {
+	count, i := 0, 0
+Outer:
+	for i = 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if j > i {
+				continue Outer
+			}
+			if i+j == 6 {
+				break Outer
+			}
+			count++
+		}
+	}
+	return count, i
+}
+
+func triples(n int) int {
+	count := 0
+Outer:
+	for i := range n {
+		for j := range n {
+			for k := range n {
+				if k > j || j > i {
+					continue Outer
+				}
+				count++
+			}
+		}
+	}
+	return count
+}
+`, "print(main.pairs(10), main.triples(4))", "(9, 3) 4\n"},
+	// A backward goto forms a loop and a forward goto skips statements
+	{`package main
+
+func sum(n int) int {
+	total, i := 0, 0
+loop:
+	if i < n {
+		i++
+		total += i
+		goto loop
+	}
+	return total
+}
+
+func f(skip bool) []string {
+	out := []string{"start"}
+	if skip {
+		goto end
+	}
+	out = append(out, "middle")
+end:
+	out = append(out, "end")
+	return out
+}
+`, "print(main.sum(4), main.f(False), main.f(True))",
+		"10 ['start', 'middle', 'end'] ['start', 'end']\n"},
+	// Chains of two and three cases that fall through, into the default case
+	{`package main
+
+func f(x int) []string {
+	out := []string{}
+	switch x {
+	case 1:
+		out = append(out, "one")
+		fallthrough
+	case 2:
+		out = append(out, "two")
+	case 3:
+		out = append(out, "three")
+		fallthrough
+	default:
+		out = append(out, "default")
+		fallthrough
+	case 4:
+		out = append(out, "four")
+	}
+	return out
+}
+`, "print(main.f(1), main.f(2), main.f(3), main.f(4), main.f(5))",
+		"['one', 'two'] ['two'] ['three', 'default', 'four'] ['four'] ['default', 'four']\n"},
+	// Nested switches and a variable named tag keep their own values
+	{`package main
+
+func f(tag, x int) string {
+	switch x {
+	case 1:
+		switch tag + 1 {
+		case 2:
+			return "one one"
+		case 3:
+			return "two one"
+		}
+		if tag == 3 {
+			return "three one"
+		}
+	}
+	return "none"
+}
+`, "print(main.f(1, 1), main.f(2, 1), main.f(3, 1), main.f(1, 2))", "one one two one three one none\n"},
+	// break inside a switch leaves the switch and continue inside one
+	// continues the loop around it
+	{`package main
+
+func f() (int, int, int) {
+	small, odd, i := 0, 0, 0
+	for i = 0; i < 10; i++ {
+		switch {
+		case i%2 == 0:
+			if i > 4 {
+				break
+			}
+			small++
+		default:
+			if i > 6 {
+				continue
+			}
+			odd++
+		}
+	}
+	return small, odd, i
+}
+`, "print(main.f())", "(3, 3, 10)\n"},
+	// A multi-return assigned to struct fields calls each target's function
+	// once, before the function on the right
+	{`package main
+
+type T struct{ a, b int }
+
+type Log struct{ calls []string }
+
+var log = &Log{calls: []string{}}
+
+func getObj(t *T) *T {
+	log.calls = append(log.calls, "getObj")
+	return t
+}
+
+func f() (int, int) {
+	log.calls = append(log.calls, "f")
+	return 1, 2
+}
+
+func g() (int, int, []string) {
+	var t T
+	getObj(&t).a, getObj(&t).b = f()
+	return t.a, t.b, log.calls
+}
+`, "print(main.g())", "(1, 2, ['getObj', 'getObj', 'f'])\n"},
+	// Calls deeper in the targets are made before the function on the right too
+	{`package main
+
+type Inner struct{ a int }
+
+type T struct{ inner Inner }
+
+type Log struct{ calls []string }
+
+var log = &Log{calls: []string{}}
+
+func getObj(t *T) *T {
+	log.calls = append(log.calls, "getObj")
+	return t
+}
+
+func index() int {
+	log.calls = append(log.calls, "index")
+	return 1
+}
+
+func f() (int, int) {
+	log.calls = append(log.calls, "f")
+	return 1, 2
+}
+
+func g() (int, []int, []string) {
+	var t T
+	xs := []int{0, 0}
+	getObj(&t).inner.a, xs[index()] = f()
+	return t.inner.a, xs, log.calls
+}
+`, "print(main.g())", "(1, [0, 2], ['getObj', 'index', 'f'])\n"},
+	// A deferred function recovers the panic and its value
+	{`package main
+
+type box struct{ v interface{} }
+
+func f(b *box) {
+	defer func() { b.v = recover() }()
+	panic("boom")
+}
+`, "b = main.box()\nmain.f(b)\nprint(b.v)", "boom\n"},
+	// recover returns nil when there is no panic
+	{`package main
+
+type box struct{ v interface{} }
+
+func f(b *box) {
+	defer func() { b.v = recover() }()
+}
+`, "b = main.box(1)\nmain.f(b)\nprint(b.v)", "None\n"},
+	// recover outside a deferred call returns nil, and a recovered panic
+	// value keeps its type
+	{`package main
+
+type fault struct{ code int }
+
+func direct() interface{} { return recover() }
+
+func f() (code int) {
+	defer func() { code = recover().(fault).code }()
+	panic(fault{7})
+}
+`, "print(main.direct(), main.f())", "None 7\n"},
+	// A function that recovers returns its named results as the deferred
+	// function set them
+	{`package main
+
+func f() (r int) {
+	defer func() {
+		recover()
+		r = 5
+	}()
+	r = 3
+	panic("x")
+}
+
+func g() (r int, s string) {
+	defer func() { s = recover().(string) }()
+	r = 3
+	panic("x")
+}
+`, "print(main.f(), main.g())", "5 (3, 'x')\n"},
+	// A return sets the named results before the deferred functions run,
+	// and they return as the deferred functions set them
+	{`package main
+
+func f() (x int) {
+	defer func() { x *= 2 }()
+	return 3
+}
+
+func g(n int) (x int, err error) {
+	defer func() { x++ }()
+	if n > 0 {
+		return n, nil
+	}
+	x = 10
+	return
+}
+`, "print(main.f(), main.g(4), main.g(0))", "6 (5, None) (11, None)\n"},
+	// Errors that Python raises, such as an index out of range, are panics
+	// that recover stops
+	{`package main
+
+func index(xs []int, i int) (v int, ok bool) {
+	defer func() {
+		if recover() != nil {
+			v, ok = -1, false
+		}
+	}()
+	return xs[i], true
+}
+
+func divide(a, b int) (q int) {
+	defer func() { recover() }()
+	return a / b
+}
+`, "print(main.index([1, 2], 1), main.index([1, 2], 5), main.divide(7, 2), main.divide(1, 0))", "(2, True) (-1, False) 3 0\n"},
+	// A function literal assigns to the variables of the enclosing function
+	{`package main
+
+func f() int {
+	n := 0
+	add := func(x int) { n += x }
+	add(1)
+	add(2)
+	return n
+}
+`, "print(main.f())", "3\n"},
+	// A deferred method call evaluates its receiver when it is deferred, so
+	// reassigning the variable afterwards does not change what it prints
+	{`package main
+
+import "fmt"
+
+type greeter struct{ name string }
+
+func (g greeter) Hello() { fmt.Println("hello", g.name) }
+
+func (g *greeter) Bye() { fmt.Println("bye", g.name) }
+
+func f() {
+	g := greeter{"first"}
+	defer g.Hello()
+	p := &greeter{"one"}
+	defer p.Bye()
+	g = greeter{"second"}
+	p = &greeter{"two"}
+	fmt.Println("now", g.name, p.name)
+}
+`, "main.f()", "now second two\nbye one\nhello first\n"},
+	// Deferred functions run last in, first out, and only those deferred
+	// before a panic run
+	{`package main
+
+type trace struct{ order []int }
+
+func f(t *trace, fail bool) {
+	defer func() { recover() }()
+	defer func() { t.order = append(t.order, 1) }()
+	defer func() { t.order = append(t.order, 2) }()
+	if fail {
+		panic("boom")
+	}
+	defer func() { t.order = append(t.order, 3) }()
+}
+`, `
+t = main.trace([])
+main.f(t, True)
+print(t.order)
+t = main.trace([])
+main.f(t, False)
+print(t.order)
+`, "[2, 1]\n[3, 2, 1]\n"},
+	// A defer in a loop registers one deferred call per iteration, with the
+	// receiver of that iteration, and they all run last in, first out when
+	// the function returns
+	{`package main
+
+type log struct{ closed []string }
+
+type file struct {
+	name string
+	log  *log
+}
+
+func (f *file) Close() { f.log.closed = append(f.log.closed, f.name) }
+
+func open(name string, l *log) *file { return &file{name, l} }
+
+func f(names []string, l *log) int {
+	opened := 0
+	for _, name := range names {
+		fp := open(name, l)
+		defer fp.Close()
+		opened++
+	}
+	l.closed = append(l.closed, "return")
+	return opened
+}
+`, `
+l = main.log([])
+print(main.f(["a", "b", "c"], l), l.closed)
+`, "3 ['return', 'c', 'b', 'a']\n"},
+	// A variadic function ranges over and indexes its arguments, which are
+	// passed individually or spread from a slice
+	{`package main
+
+type logger struct {
+	entries []interface{}
+	first   interface{}
+}
+
+func (l *logger) log(args ...interface{}) {
+	for _, arg := range args {
+		l.entries = append(l.entries, arg)
+	}
+	if len(args) > 0 {
+		l.first = args[0]
+	}
+}
+
+func f(l *logger) {
+	l.log(1, "a", true)
+	xs := []interface{}{2.5, "b"}
+	l.log(xs...)
+	l.log()
+}
+`, "l = main.logger([])\nmain.f(l)\nprint(l.entries, l.first)", "[1, 'a', True, 2.5, 'b'] 2.5\n"},
+	// A conversion to a named slice type wraps the slice, which its methods
+	// len, index, slice and range over
+	{`package main
+
+type Bytes []byte
+
+func (b Bytes) Sum() int {
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	return sum + len(b) + int(b[0])
+}
+
+func (b Bytes) Tail() Bytes { return b[1:] }
+
+func f(s string) (int, int, string) {
+	b := Bytes([]byte(s))
+	return b.Sum(), b.Tail().Sum(), string(b.Tail())
+}
+`, "print(main.f(\"abc\"))", "(394, 297, 'bc')\n"},
+	// Ranging over a channel drains it until it is closed
+	{`package main
+
+type counter struct{ n int }
+
+func drain(ch chan int, c *counter) {
+	for range ch {
+		c.n++
+	}
+}
+`, `
+import runtime
+ch = runtime.Chan(3)
+ch.send(1)
+ch.send(2)
+ch.close()
+c = main.counter()
+main.drain(ch, c)
+print(c.n, ch.recvOk())
+`, "2 (None, False)\n"},
+	// Structs used as map keys are hashed and compared by their fields
+	{`package main
+
+type Point struct{ X, Y int }
+
+func f() (int, bool, bool) {
+	m := map[Point]int{Point{1, 2}: 3}
+	m[Point{4, 5}] = 6
+	_, ok := m[Point{2, 1}]
+	return m[Point{1, 2}] + m[Point{4, 5}], ok, Point{1, 2} == Point{1, 2}
+}
+`, "print(main.f())", "(9, False, True)\n"},
+	// Reading from a nil map gives the zero value, deleting from it does
+	// nothing, and writing to it panics
+	{`package main
+
+func read() (int, int, bool, int) {
+	var m map[string]int
+	v, ok := m["a"]
+	delete(m, "a")
+	return m["a"], v, ok, len(m)
+}
+
+func write() (r interface{}) {
+	defer func() { r = recover() }()
+	var m map[string]int
+	m["a"] = 1
+	return nil
+}
+`, "print(main.read(), main.write())", "(0, 0, False, 0) assignment to entry in nil map\n"},
+	// Updating a missing entry of a map updates its zero value, and updating
+	// an entry of a nil map panics
+	{`package main
+
+type Counts map[string]int
+
+func (c Counts) Inc(k string) { c[k]++ }
+
+func count(words []string) (map[string]int, Counts) {
+	lengths := map[string]int{}
+	counts := Counts{}
+	for _, w := range words {
+		lengths[w] += len(w)
+		counts.Inc(w)
+	}
+	return lengths, counts
+}
+
+func write() (r interface{}) {
+	defer func() { r = recover() }()
+	var c Counts
+	c.Inc("a")
+	return nil
+}
+`, "l, c = main.count(['a', 'bb', 'a'])\nprint(l, dict(c), main.write())", "{'a': 2, 'bb': 2} {'a': 2, 'bb': 1} assignment to entry in nil map\n"},
+	// A labeled break leaves the select or loops nested in the loop it labels
+	{`package main
+
+func send(ch chan int) int {
+	n := 0
+Loop:
+	for {
+		select {
+		case ch <- n:
+			n++
+			if n == 3 {
+				break Loop
+			}
+		}
+	}
+	return n
+}
+
+func count() int {
+	n := 0
+Outer:
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for range "ab" {
+				if i == 1 && j == 2 {
+					break Outer
+				}
+			}
+			n++
+		}
+	}
+	return n
+}
+`, `
+import runtime
+ch = runtime.Chan(5)
+print(main.send(ch), len(ch), main.count())
+`, "3 3 5\n"},
+	// Rune constants are integers until they are converted to strings
+	{`package main
+
+const nl = '\n'
+
+func f() (int, string, string, bool) {
+	s := "a" + string(nl)
+	return nl + 1, string(nl), s, []rune(s)[1] == nl
+}
+`, "print(main.f())", "(11, '\\n', 'a\\n', True)\n"},
+	// A panic that is not recovered propagates to the caller
+	{`package main
+
+func f() {
+	defer func() {}()
+	panic("boom")
+}
+`, `
+try:
+    main.f()
+except main.runtime.GoPanic as p:
+    print(p.value)
+`, "boom\n"},
+	// A deferred function can recover a panic, inspect its value and panic
+	// again, which propagates to the caller
+	{`package main
+
+type box struct{ seen interface{} }
+
+func f(b *box, v string) {
+	defer func() {
+		r := recover()
+		b.seen = r
+		if r != "ok" {
+			panic("again: " + r.(string))
+		}
+	}()
+	panic(v)
+}
+
+func g(b *box) (r interface{}) {
+	defer func() { r = recover() }()
+	f(b, "bad")
+	return nil
+}
+`, `
+b = main.box()
+main.f(b, "ok")
+print(b.seen)
+try:
+    main.f(b, "bad")
+except main.runtime.GoPanic as p:
+    print(b.seen, p.value)
+print(main.g(b))
+`, "ok\nbad again: bad\nagain: bad\n"},
+	// ParseInt and ParseUint return an error for invalid syntax and values
+	// out of the range of the bit size
+	{`package main
+
+import "strconv"
+
+func parse(s string, bitSize int) string {
+	n, err := strconv.ParseInt(s, 0, bitSize)
+	if err != nil {
+		return strconv.FormatInt(n, 10) + " " + err.Error()
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func parseUint(s string) string {
+	n, err := strconv.ParseUint(s, 16, 8)
+	if err != nil {
+		return strconv.FormatUint(n, 10) + " " + err.Error()
+	}
+	return strconv.FormatUint(n, 10)
+}
+`, `for s, bitSize in [("0x_1F", 64), ("-128", 8), ("200", 8), ("1x", 64), (" 1", 0)]:
+    print(main.parse(s, bitSize))
+for s in ["ff", "100", "-1"]:
+    print(main.parseUint(s))`, `31
+-128
+127 strconv.ParseInt: parsing "200": value out of range
+0 strconv.ParseInt: parsing "1x": invalid syntax
+0 strconv.ParseInt: parsing " 1": invalid syntax
+255
+255 strconv.ParseUint: parsing "100": value out of range
+0 strconv.ParseUint: parsing "-1": invalid syntax
+`},
+	// Wait returns once the goroutines that a WaitGroup counts have finished
+	{`package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+type results struct {
+	mu  sync.Mutex
+	sum int
+}
+
+func f() int {
+	var wg sync.WaitGroup
+	r := &results{}
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			runtime.Gosched()
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.sum += n
+		}(i)
+	}
+	wg.Wait()
+	return r.sum
+}
+`, "print(main.f())", "55\n"},
+	// A goroutine runs a method bound to the receiver the go statement
+	// evaluated, with its argument
+	{`package main
+
+import "sync"
+
+type counter struct {
+	name  string
+	added []int
+	wg    *sync.WaitGroup
+}
+
+func (c *counter) Add(n int) {
+	defer c.wg.Done()
+	c.added = append(c.added, n)
+}
+
+func f() (string, []int) {
+	var wg sync.WaitGroup
+	first := &counter{"first", []int{}, &wg}
+	c := first
+	wg.Add(1)
+	go c.Add(3)
+	c = &counter{"second", []int{}, &wg}
+	wg.Wait()
+	return first.name, first.added
+}
+`, "print(main.f())", "('first', [3])\n"},
+	// A func-typed field starts as nil and calls the function stored in it
+	{`package main
+
+type Handler struct {
+	name    string
+	OnClick func(int) string
+}
+
+func (h *Handler) Click(n int) string {
+	if h.OnClick == nil {
+		return h.name + " ignored"
+	}
+	return h.OnClick(n)
+}
+
+func f() (string, string) {
+	h := &Handler{name: "button"}
+	before := h.Click(1)
+	h.OnClick = func(n int) string { return h.name + " clicked " + string(rune('0'+n)) }
+	return before, h.Click(2)
+}
+`, "print(main.f())", "('button ignored', 'button clicked 2')\n"},
+	// Computed values sent to a channel in a struct field are received in
+	// order, from a goroutine waiting for each to be received
+	{`package main
+
+type pipe struct {
+	values chan int
+}
+
+func (p *pipe) put(xs []int) {
+	for i, x := range xs {
+		p.values <- x*10 + i
+	}
+}
+
+func start(xs []int) *pipe {
+	p := &pipe{make(chan int)}
+	go p.put(xs)
+	return p
+}
+`, `
+p = main.start([1, 2, 3])
+print([p.values.recv() for _ in range(3)])
+`, "[10, 21, 32]\n"},
+	// A receive waits for a value, and the comma-ok form reports whether the
+	// channel was closed and drained
+	{`package main
+
+func f(ch chan string) (string, string, bool, string, bool) {
+	first := <-ch
+	second, ok := <-ch
+	third, closed := <-ch
+	return first, second, ok, third, closed
+}
+`, `
+import runtime
+ch = runtime.Chan(2, "")
+ch.send("a")
+ch.send("b")
+ch.close()
+print(main.f(ch))
+`, "('a', 'b', True, '', False)\n"},
+	// A slice made with a capacity is empty until it is appended to
+	{`package main
+
+func f(n int) (int, int, []int) {
+	xs := make([]int, 0, n)
+	empty := len(xs)
+	for i := range n {
+		xs = append(xs, i*i)
+	}
+	return empty, len(xs), xs
+}
+`, "print(main.f(4))", "(0, 4, [0, 1, 4, 9])\n"},
+	// Goroutines started on a named function and on a closure each get the
+	// arguments of their go statement
+	{`package main
+
+import "sync"
+
+type sums struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	values []int
+}
+
+func add(s *sums, a, b int) {
+	defer s.wg.Done()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = append(s.values, a+b)
+}
+
+func f() int {
+	s := &sums{values: []int{}}
+	s.wg.Add(2)
+	go add(s, 1, 2)
+	n := 10
+	go func(m int) {
+		add(s, m, 20)
+	}(n)
+	n = 0
+	s.wg.Wait()
+	total := 0
+	for _, v := range s.values {
+		total += v
+	}
+	return total + n
+}
+`, "print(main.f())", "33\n"},
+	// A module with typed constants can be imported
+	{`package main
+
+const Root ID = "root"
+
+type ID string
+`, "print(main.Root.value)", "root\n"},
+	// Errors can be raised and caught as exceptions
+	{`package main
+
+import "fmt"
+
+type codeError struct{ code int }
+
+func (e *codeError) Error() string { return fmt.Sprintf("code %d", e.code) }
+
+func fail() { panic(&codeError{3}) }
+`, `try:
+    raise main.codeError(2)
+except Exception as e:
+    print(e)
+try:
+    main.fail()
+except main.runtime.GoPanic as p:
+    print(isinstance(p.value, Exception), p.value)`, "code 2\nTrue code 3\n"},
+}
+
+func TestRuntime(t *testing.T) {
+	for _, test := range runtimeTests {
+		stdout, stderr, err := runPython(t, test.golang, test.script, Options{})
+		if err != nil {
+			t.Errorf("%s\n%s: %s", test.golang, err, stderr)
+			continue
+		}
+		if stdout != test.want {
+			t.Errorf("%s\nwant: %q\ngot:  %q", test.golang, test.want, stdout)
+		}
+	}
+}
+
+// A panic that a goroutine does not recover ends the program
+func TestGoroutinePanic(t *testing.T) {
+	const golang = `package main
+
+func f() {
+	go func() { panic("boom") }()
+}
+`
+	stdout, stderr, err := runPython(t, golang, "import time\nprint('started')\nmain.f()\ntime.sleep(10)\nprint('not reached')", Options{})
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("want exit status 2, got %v: %s", err, stderr)
+	}
+	if !strings.HasPrefix(stderr, "panic: boom\n") {
+		t.Errorf("want the panic on stderr, got %q", stderr)
+	}
+	if stdout != "started\n" {
+		t.Errorf("want the output before the panic, got %q", stdout)
+	}
+}
+
+// The files of a package are compiled to one module in which unexported
+// identifiers declared in one file are the same in the others
+func TestMultipleFiles(t *testing.T) {
+	const a = `package main
+
+func F() int {
+	c := counter{}
+	c.add(offset)
+	return twice(c.n)
+}
+`
+	const b = `package main
+
+type counter struct{ n int }
+
+func (c *counter) add(n int) { c.n += n }
+
+var offset = 3
+
+func twice(x int) int { return x * 2 }
+`
+	pkg, errs := buildPackage(token.NewFileSet(), a, b)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	module := NewCompiler(&pkg.Info, nil).CompileFiles(pkg.Files)
+	stdout, stderr, err := runModule(t, module, "print(main.F())")
+	if err != nil {
+		t.Fatalf("%s: %s", err, stderr)
+	}
+	if want := "6\n"; stdout != want {
+		t.Errorf("want %q, got %q", want, stdout)
+	}
+}
+
+// Typed constants declared with iota have the same values whether their type
+// is compiled to a wrapper class or an IntEnum
+func TestEnums(t *testing.T) {
+	const golang = `package main
+
+type Weekday int
+
+const (
+	_ Weekday = iota
+	Sunday
+	Monday
+)
+
+func (d Weekday) weekend() bool { return d == Sunday }
+
+func f() (int, int, bool, bool) {
+	var d Weekday
+	return int(Sunday), int(Monday), Sunday.weekend() && !Monday.weekend(), d < Sunday
+}
+
+func day(n int) (Weekday, Weekday) { return Weekday(2), Weekday(n) }
+
+func step(n int) (int, bool, int, bool) {
+	d := Sunday
+	d++
+	e := Sunday + Weekday(n)
+	e += 1
+	return int(d + 1), d == Monday, int(e), (-d).weekend()
+}
+
+func later(d Weekday, n int) Weekday { return d + Weekday(n) }
+`
+	tests := []struct {
+		options Options
+		script  string
+		want    string
+	}{
+		{Options{}, "print(main.f(), type(main.Monday).__name__)", "(1, 2, True, True) Weekday\n"},
+		{Options{Enums: true}, "import enum\nprint(main.f(), main.Monday is main.Weekday.Monday, isinstance(main.Monday, enum.IntEnum))", "(1, 2, True, True) True True\n"},
+		// Converting an int gives its member, and a value that is not one of
+		// the constants is a Weekday as in Go rather than a ValueError
+		{Options{}, "a, b = main.day(9)\nprint(a.value, b.value)", "2 9\n"},
+		{Options{Enums: true}, "a, b = main.day(9)\nprint(a is main.Monday, int(b), isinstance(b, main.Weekday), b in list(main.Weekday))", "True 9 True False\n"},
+		// Arithmetic on an IntEnum gives a member of the same IntEnum
+		{Options{Enums: true}, "print(main.step(3), main.later(main.Sunday, 1) is main.Monday, type(main.later(main.Monday, 5)).__name__)", "(3, True, 5, False) True Weekday\n"},
+	}
+	for _, test := range tests {
+		stdout, stderr, err := runPython(t, golang, test.script, test.options)
+		if err != nil {
+			t.Errorf("%+v: %s: %s", test.options, err, stderr)
+			continue
+		}
+		if stdout != test.want {
+			t.Errorf("%+v\nwant: %q\ngot:  %q", test.options, test.want, stdout)
+		}
+	}
+}
+
+// A nil map compiled to a set is read as an empty set
+func TestNilSet(t *testing.T) {
+	const golang = `package main
+
+func f() (bool, int, bool) {
+	var seen map[string]struct{}
+	_, ok := seen["a"]
+	delete(seen, "a")
+	n := len(seen)
+	for range seen {
+		n++
+	}
+	_, found := seen["a"]
+	return ok, n, found
+}
+`
+	stdout, stderr, err := runPython(t, golang, "print(main.f())", Options{Sets: true})
+	if err != nil {
+		t.Fatalf("%s: %s", err, stderr)
+	}
+	if want := "(False, 0, False)\n"; stdout != want {
+		t.Errorf("want %q, got %q", want, stdout)
+	}
+}
+
+func TestRuntimeImport(t *testing.T) {
+	tests := []struct {
+		golang string
+		want   bool
+	}{
+		{"package main\nfunc f() { defer f() }", true},
+		{"package main\nfunc f() { panic(1) }", true},
+		{"package main\nfunc f() interface{} { return recover() }", true},
+		{"package main\nfunc f() { go f() }", true},
+		{"package main\nimport \"sync\"\nvar mu sync.Mutex", true},
+		{"package main\nimport \"fmt\"\nvar s = fmt.Sprintf(\"%q\", \"q\")", true},
+		{"package main\nimport \"fmt\"\nvar s = fmt.Sprintf(\"%v\", error(nil))", true},
+		{"package main\nimport \"fmt\"\nvar s = fmt.Sprintf(\"%d %q\", 1, \"q\")", true},
+		{"package main\nimport \"fmt\"\nvar s = fmt.Sprintf(\"quit %v\", 1)", false},
+		{"package main\nimport \"fmt\"\nvar s = fmt.Sprintf(\"%*q\", 4, \"q\")", true},
+		{"package main\nimport \"fmt\"\nfunc f(format string) string { return fmt.Sprintf(format, 1) }", true},
+		{"package main\nimport \"fmt\"\nfunc f(args []interface{}) string { return fmt.Sprintf(\"%d\", args...) }", true},
+		{"package main\nimport \"fmt\"\nfunc f(err error) { fmt.Println(err) }", true},
+		{"package main\nimport \"fmt\"\nfunc f(ok bool) { fmt.Println(ok) }", false},
+		{"package main\nimport \"strconv\"\nvar n, err = strconv.ParseInt(\"1\", 10, 64)", true},
+		{"package main\nimport \"strconv\"\nvar s = strconv.FormatInt(1, 16)", false},
+		{"package main\nfunc f(m map[int]int) { m[0] = 1 }", true},
+		{"package main\nfunc f(m map[int]int) { m[0]++ }", true},
+		{"package main\nfunc f(m map[int]int) int { return m[0] }", false},
+		{"package main\nfunc f(m map[int]int) { m[0] = 1 }", true},
+		{"package main\nfunc f(m map[int]int) { m[0]++ }", true},
+		{"package main\nfunc f(m map[int]int) int { return m[0] }", false},
+		{"package main\ntype T struct{ x int }\nfunc (t T) f() T { t.x = 1; return t }", false},
+		{"package main\ntype T struct{ x int }\nfunc (t *T) f() *T { t.x = 1; return t }", false},
+		{"package main\ntype T struct{ x int }\nfunc (t T) f() int { return t.x }", false},
+		{"package main\nvar ch = make(chan int)", true},
+		{"package main\nfunc f(ch chan int) { select { case ch <- 1: } }", true},
+		{"package main\nfunc f(ch chan int) { select { case ch <- 1: default: } }", false},
+		{"package main\nvar m = make(map[int]int)", false},
+	}
+	for _, test := range tests {
+		pkg, file, errs := buildFile(test.golang)
+		if errs != nil {
+			t.Fatal(errs)
+		}
+		module := NewCompiler(&pkg.Info, nil).CompileFiles([]*ast.File{file})
+		imported := false
+		for _, stmt := range module.Body {
+			if imp, ok := stmt.(*py.Import); ok && imp.Names[0].Name == runtimeModule.Id {
+				imported = true
+			}
+		}
+		if imported != test.want {
+			t.Errorf("%q: imports runtime: want %v, got %v", test.golang, test.want, imported)
+		}
+	}
+}
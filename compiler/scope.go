@@ -6,23 +6,29 @@ import (
 	"go/types"
 )
 
+// SymbolTable maps Go objects to the Python identifiers they were compiled to.
+type SymbolTable map[types.Object]py.Identifier
+
 // Python scope
 type scope struct {
-	parent *scope
-	ids    map[types.Object]py.Identifier
-	locals map[py.Identifier]bool
+	parent  *scope
+	ids     map[types.Object]py.Identifier
+	locals  map[py.Identifier]bool
+	symbols SymbolTable // shared by all nested scopes
 }
 
 func newScope() *scope {
 	return &scope{
-		ids:    make(map[types.Object]py.Identifier),
-		locals: make(map[py.Identifier]bool),
+		ids:     make(map[types.Object]py.Identifier),
+		locals:  make(map[py.Identifier]bool),
+		symbols: make(SymbolTable),
 	}
 }
 
 func (s *scope) nested() *scope {
 	ns := newScope()
 	ns.parent = s
+	ns.symbols = s.symbols
 	return ns
 }
 
@@ -36,9 +42,17 @@ func (s *scope) objID(goID types.Object) py.Identifier {
 	}
 	s.ids[goID] = pyID
 	s.locals[pyID] = true
+	if _, ok := s.symbols[goID]; !ok {
+		s.symbols[goID] = pyID
+	}
 	return pyID
 }
 
+// SymbolTable returns the Python identifier of each Go object compiled so far.
+func (s *scope) SymbolTable() SymbolTable {
+	return s.symbols
+}
+
 func (s *scope) tempID(baseId string) py.Identifier {
 	pyID := py.Identifier(baseId)
 	for i := 1; s.locals[pyID]; i++ {
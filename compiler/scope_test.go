@@ -2,6 +2,7 @@ package compiler
 
 import (
 	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
 	"go/token"
 	"go/types"
 	"testing"
@@ -12,10 +13,10 @@ func Test_scope_id_different_idents(t *testing.T) {
 	x1 := scope.objID(types.NewVar(token.NoPos, nil, "x", nil))
 	x2 := scope.objID(types.NewVar(token.NoPos, nil, "x", nil))
 	if x1 != py.Identifier("x") {
-		t.Errorf("x1=", x1)
+		t.Errorf("x1=%s", x1)
 	}
 	if x2 != py.Identifier("x1") {
-		t.Errorf("x2=", x2)
+		t.Errorf("x2=%s", x2)
 	}
 }
 
@@ -25,9 +26,44 @@ func Test_scope_id_same_idents(t *testing.T) {
 	x1 := scope.objID(x)
 	x2 := scope.objID(x)
 	if x1 != py.Identifier("x") {
-		t.Errorf("x1=", x1)
+		t.Errorf("x1=%s", x1)
 	}
 	if x2 != py.Identifier("x") {
-		t.Errorf("x2=", x2)
+		t.Errorf("x2=%s", x2)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	pkg, file, errs := buildFile(`package main
+
+type T struct{ x int }
+
+func f() { x := T{}; { x := 1; _ = x }; _ = x }
+`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	c := NewCompiler(&pkg.Info, nil)
+	c.CompileFiles([]*ast.File{file})
+	symbols := c.SymbolTable()
+
+	f := pkg.Pkg.Scope().Lookup("f")
+	if symbols[f] != py.Identifier("f") {
+		t.Errorf("f=%s", symbols[f])
+	}
+	field := pkg.Pkg.Scope().Lookup("T").Type().Underlying().(*types.Struct).Field(0)
+	if symbols[field] != py.Identifier("x") {
+		t.Errorf("T.x=%s", symbols[field])
+	}
+	// The inner x is renamed because Python has function scope
+	var inner *ast.Ident
+	ast.Inspect(file, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Name == "x" && pkg.Defs[ident] != nil {
+			inner = ident
+		}
+		return true
+	})
+	if id := symbols[pkg.Defs[inner]]; id != py.Identifier("x1") {
+		t.Errorf("inner x=%s", id)
 	}
 }
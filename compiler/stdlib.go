@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/types"
+	"strconv"
+)
+
+// compiledPackages are the packages that are not imported because the
+// compiler translates their members to Python builtins or the runtime module,
+// or because they have no Python counterpart.
+var compiledPackages = map[string]bool{
+	"C":       true,
+	"fmt":     true,
+	"runtime": true,
+	"strconv": true,
+	"strings": true,
+	"unsafe":  true,
+}
+
+// translatedPackages are the compiled packages whose members are translated
+// one by one. A member without a translation is not supported unless the
+// package is imported as a Python module.
+var translatedPackages = map[string]bool{
+	"fmt":     true,
+	"strconv": true,
+	"strings": true,
+}
+
+// checkTranslated panics if the package with path has no Python module and
+// its member name, which expr refers to, has no translation.
+func (c *Compiler) checkTranslated(expr ast.Node, path string, name string) {
+	if _, ok := c.Options.Modules[path]; translatedPackages[path] && !ok {
+		panic(c.err(expr, "%s.%s is not supported", path, name))
+	}
+}
+
+// importedPackage returns the package that expr refers to if it is the name
+// of an imported package, otherwise nil.
+func (c *Compiler) importedPackage(expr ast.Expr) *types.Package {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	pkgName, ok := c.ObjectOf(ident).(*types.PkgName)
+	if !ok {
+		return nil
+	}
+	return pkgName.Imported()
+}
+
+// compilePackageCall compiles a call to a function in a standard library
+// package that has a direct Python translation.
+// It returns nil if the function should be called as is.
+func (c *exprCompiler) compilePackageCall(path string, name string, expr *ast.CallExpr) py.Expr {
+	switch path {
+	case "fmt":
+		if compiled := c.compileFmtCall(name, expr); compiled != nil {
+			return compiled
+		}
+	case "strconv":
+		if compiled := c.compileStrconvCall(name, expr); compiled != nil {
+			return compiled
+		}
+	case "strings":
+		if compiled := c.compileStringsCall(name, expr); compiled != nil {
+			return compiled
+		}
+	}
+	c.checkTranslated(expr, path, name)
+	return nil
+}
+
+// compilePackageSelector compiles a reference to a member of a standard
+// library package that has a direct Python translation.
+// It returns nil if the member should be referenced as is.
+func (c *exprCompiler) compilePackageSelector(path string, name string) py.Expr {
+	if path != "runtime" {
+		return nil
+	}
+	var value string
+	switch name {
+	case "GOOS":
+		value = c.Options.GOOS
+	case "GOARCH":
+		value = c.Options.GOARCH
+	}
+	if value == "" {
+		return nil
+	}
+	return &py.Str{S: strconv.Quote(value)}
+}
@@ -0,0 +1,51 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"testing"
+)
+
+var runtimeGOOS = &py.Attribute{Value: runtimeModule, Attr: py.Identifier("GOOS")}
+
+// runtime.GOOS and runtime.GOARCH are constants of the configured target
+var targetTests = []stmtTest{
+	{`if runtime.GOOS == "windows" { s(0) }`, []py.Stmt{
+		&py.If{
+			Test: &py.Compare{
+				Left:        &py.Str{S: `"windows"`},
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{&py.Str{S: `"windows"`}},
+			},
+			Body: s(0),
+		},
+	}},
+	{`if runtime.GOARCH == "amd64" { s(0) }`, []py.Stmt{
+		&py.If{
+			Test: &py.Compare{
+				Left:        &py.Str{S: `"arm64"`},
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{&py.Str{S: `"amd64"`}},
+			},
+			Body: s(0),
+		},
+	}},
+}
+
+// With no target configured they are read from the runtime module
+var noTargetTests = []stmtTest{
+	{`if runtime.GOOS == "windows" { s(0) }`, []py.Stmt{
+		&py.If{
+			Test: &py.Compare{
+				Left:        runtimeGOOS,
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{&py.Str{S: `"windows"`}},
+			},
+			Body: s(0),
+		},
+	}},
+}
+
+func TestStdlib(t *testing.T) {
+	runStmtTests(t, targetTests, Options{GOOS: "windows", GOARCH: "arm64"})
+	runStmtTests(t, noTargetTests, Options{})
+}
@@ -5,9 +5,16 @@ import (
 	py "github.com/mbergin/gotopython/pythonast"
 	"go/ast"
 	"go/token"
-	"strings"
+	"go/types"
+	"strconv"
 )
 
+// isTrailingComment reports whether comment follows stmt on the line it ends on.
+func (c *Compiler) isTrailingComment(stmt ast.Stmt, comment *ast.CommentGroup) bool {
+	return comment.Pos() >= stmt.End() &&
+		c.FileSet.Position(comment.Pos()).Line == c.FileSet.Position(stmt.End()).Line
+}
+
 func (c *Compiler) compileStmts(stmts []ast.Stmt) []py.Stmt {
 	var pyStmts []py.Stmt
 	for _, blockStmt := range stmts {
@@ -23,12 +30,112 @@ func (c *Compiler) isBlank(expr ast.Expr) bool {
 
 func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) []py.Stmt {
 	e := c.exprCompiler()
+	outerPost, outerFlag := c.continuePost, c.continueFlag
+	c.continuePost, c.continueFlag = nil, nil
 	body := c.compileStmt(stmt.Body)
-	if len(body) == 0 {
+	c.continuePost, c.continueFlag = outerPost, outerFlag
+	emptyBody := len(body) == 0
+	if emptyBody {
 		body = []py.Stmt{&py.Pass{}}
 	}
 	var pyStmt py.Stmt
-	if stmt.Key != nil && stmt.Value == nil {
+	if _, ok := c.TypeOf(stmt.X).Underlying().(*types.Chan); ok {
+		// for v := range ch { ... } receives until the channel is closed and drained
+		// while True:
+		//     v, ok = ch.recvOk()
+		//     if not ok:
+		//         break
+		//     ...
+		// A nil channel would block forever, so it raises runtime.Deadlock:
+		// chan = runtime.rangeChan(ch)
+		var ch py.Expr = e.compileExpr(stmt.X)
+		if c.mayBeNil(stmt.X) {
+			ch = &py.Call{Func: goRangeChan, Args: []py.Expr{ch}}
+		}
+		ch = e.evaluateValueOnce(ch, "chan")
+		var value py.Expr = &py.Name{Id: py.Identifier("_")}
+		if stmt.Key != nil {
+			value = e.compileExpr(stmt.Key)
+		}
+		ok := &py.Name{Id: c.tempID("ok")}
+		recv := &py.Assign{
+			Targets: []py.Expr{&py.Tuple{Elts: []py.Expr{value, ok}}},
+			Value:   &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("recvOk")}},
+		}
+		closed := &py.If{
+			Test: &py.UnaryOpExpr{Op: py.Not, Operand: ok},
+			Body: []py.Stmt{&py.Break{}},
+		}
+		loop := []py.Stmt{recv, closed}
+		if !emptyBody {
+			loop = append(loop, body...)
+		}
+		pyStmt = &py.While{Test: pyTrue, Body: loop}
+	} else if t, ok := c.TypeOf(stmt.X).Underlying().(*types.Basic); ok && t.Info()&types.IsInteger != 0 {
+		// for i := range n { ... }    for i in range(n): ...
+		var target py.Expr = &py.Name{Id: py.Identifier("_")}
+		if stmt.Key != nil {
+			target = e.compileExpr(stmt.Key)
+		}
+		pyStmt = &py.For{
+			Target: target,
+			Iter:   &py.Call{Func: pyRange, Args: []py.Expr{e.compileUnwrapped(stmt.X)}},
+			Body:   body,
+		}
+	} else if isString(c.TypeOf(stmt.X)) && stmt.Key != nil {
+		// Strings are ranged over by rune, keyed by the byte offset of each
+		// for i, r := range s { ... }    for i, r in runtime.runes(s): ...
+		// for i := range s { ... }       for i, _ in runtime.runes(s): ...
+		// for _, r := range s { ... }    for r in map(ord, s): ...
+		str := e.compileUnwrapped(stmt.X)
+		var value py.Expr = &py.Name{Id: py.Identifier("_")}
+		if stmt.Value != nil {
+			value = e.compileExpr(stmt.Value)
+		}
+		if c.isBlank(stmt.Key) {
+			pyStmt = &py.For{
+				Target: value,
+				Iter:   &py.Call{Func: pyMap, Args: []py.Expr{pyOrd, str}},
+				Body:   body,
+			}
+		} else {
+			pyStmt = &py.For{
+				Target: &py.Tuple{Elts: []py.Expr{e.compileExpr(stmt.Key), value}},
+				Iter:   &py.Call{Func: goRunes, Args: []py.Expr{str}},
+				Body:   body,
+			}
+		}
+	} else if _, ok := c.TypeOf(stmt.X).Underlying().(*types.Map); ok && !c.isSet(c.TypeOf(stmt.X)) {
+		// The body may delete entries, so the loop ranges over a list of them:
+		// for k, v := range m { ... }    for k, v in list((m or {}).items()): ...
+		// for _, v := range m { ... }    for v in list((m or {}).values()): ...
+		// for k := range m { ... }       for k in list(m or {}): ...
+		m := orEmptyMap(e.compileExpr(stmt.X))
+		var target py.Expr = &py.Name{Id: py.Identifier("_")}
+		var iter py.Expr = m
+		switch {
+		case stmt.Value != nil && c.isBlank(stmt.Key):
+			target = e.compileExpr(stmt.Value)
+			iter = &py.Call{Func: &py.Attribute{Value: m, Attr: py.Identifier("values")}}
+		case stmt.Value != nil:
+			target = &py.Tuple{Elts: []py.Expr{e.compileExpr(stmt.Key), e.compileExpr(stmt.Value)}}
+			iter = &py.Call{Func: &py.Attribute{Value: m, Attr: py.Identifier("items")}}
+		case stmt.Key != nil:
+			target = e.compileExpr(stmt.Key)
+		}
+		pyStmt = &py.For{Target: target, Iter: &py.Call{Func: pyList, Args: []py.Expr{iter}}, Body: body}
+	} else if stmt.Value == nil && c.isSet(c.TypeOf(stmt.X)) {
+		// for k in list(s or {}): ...
+		var target py.Expr = &py.Name{Id: py.Identifier("_")}
+		if stmt.Key != nil {
+			target = e.compileExpr(stmt.Key)
+		}
+		pyStmt = &py.For{
+			Target: target,
+			Iter:   &py.Call{Func: pyList, Args: []py.Expr{orEmptyMap(e.compileUnwrapped(stmt.X))}},
+			Body:   body,
+		}
+	} else if stmt.Key != nil && stmt.Value == nil {
 		pyStmt = &py.For{
 			Target: e.compileExpr(stmt.Key),
 			Iter: &py.Call{
@@ -36,7 +143,7 @@ func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) []py.Stmt {
 				Args: []py.Expr{
 					&py.Call{
 						Func: pyLen,
-						Args: []py.Expr{e.compileExpr(stmt.X)},
+						Args: []py.Expr{e.compileUnwrapped(stmt.X)},
 					},
 				}},
 			Body: body,
@@ -46,7 +153,7 @@ func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) []py.Stmt {
 		if c.isBlank(stmt.Key) {
 			pyStmt = &py.For{
 				Target: e.compileExpr(stmt.Value),
-				Iter:   e.compileExpr(stmt.X),
+				Iter:   e.compileUnwrapped(stmt.X),
 				Body:   body,
 			}
 
@@ -55,7 +162,7 @@ func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) []py.Stmt {
 				Target: &py.Tuple{Elts: []py.Expr{e.compileExpr(stmt.Key), e.compileExpr(stmt.Value)}},
 				Iter: &py.Call{
 					Func: pyEnumerate,
-					Args: []py.Expr{e.compileExpr(stmt.X)},
+					Args: []py.Expr{e.compileUnwrapped(stmt.X)},
 				},
 				Body: body,
 			}
@@ -64,7 +171,7 @@ func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) []py.Stmt {
 	} else if stmt.Key == nil && stmt.Value == nil {
 		pyStmt = &py.For{
 			Target: &py.Name{Id: py.Identifier("_")},
-			Iter:   e.compileExpr(stmt.X),
+			Iter:   e.compileUnwrapped(stmt.X),
 			Body:   body,
 		}
 	} else {
@@ -81,11 +188,7 @@ func (c *Compiler) compileIncDecStmt(s *ast.IncDecStmt) []py.Stmt {
 	} else {
 		op = py.Sub
 	}
-	stmt := &py.AugAssign{
-		Target: e.compileExpr(s.X),
-		Value:  &py.Num{N: "1"},
-		Op:     op,
-	}
+	stmt := e.compileAugAssign(s.X, op, &py.Num{N: "1"})
 	return append(e.stmts, stmt)
 }
 
@@ -104,10 +207,23 @@ func (c *Compiler) compileValueSpec(spec *ast.ValueSpec) []py.Stmt {
 	// var x, y int = 1, 2    x, y = 1, 2
 	// var x, y int = f()     x, y = f()
 
+	// Constants whose values are implicit or use iota take the value that
+	// iota gives them in this spec.
+	// const (a, b = iota, iota * 2; c, d)    a, b = 0, 0
+	//                                        c, d = 1, 2
+
+	// Constants of types compiled to IntEnum classes are their members.
+	// const (Sunday Weekday = iota)          Sunday = Weekday.Sunday
+
 	for i, ident := range spec.Names {
 		target := c.compileIdent(ident)
 
-		if len(spec.Values) == 0 {
+		if obj, ok := c.ObjectOf(ident).(*types.Const); ok && c.enumMember(obj) != nil {
+			values = append(values, c.enumMember(obj))
+		} else if ok &&
+			(len(spec.Values) == 0 || i < len(spec.Values) && c.usesIota(spec.Values[i])) {
+			values = append(values, c.compileConstant(obj.Type(), obj.Val()))
+		} else if len(spec.Values) == 0 {
 			value := c.zeroValue(c.TypeOf(ident))
 			values = append(values, value)
 		} else if i < len(spec.Values) {
@@ -133,7 +249,9 @@ func (c *Compiler) compileDeclStmt(s *ast.DeclStmt) []py.Stmt {
 		case *ast.ValueSpec:
 			compiled = c.compileValueSpec(spec)
 		case *ast.TypeSpec:
-			compiled = []py.Stmt{c.compileTypeSpec(spec)}
+			if typeStmt := c.compileTypeSpec(spec); typeStmt != nil {
+				compiled = []py.Stmt{typeStmt}
+			}
 		default:
 			panic(c.err(s, "unknown Spec: %T", spec))
 		}
@@ -170,29 +288,54 @@ func (c *Compiler) augmentedOp(t token.Token) py.Operator {
 	}
 }
 
+// compileSetAssign compiles s[k] = struct{}{} to s.add(k) and _, ok = s[k]
+// to ok = k in s when s is compiled to a set. It returns nil for other assignments.
+func (c *exprCompiler) compileSetAssign(s *ast.AssignStmt) py.Stmt {
+	if s.Tok != token.ASSIGN && s.Tok != token.DEFINE {
+		return nil
+	}
+	if len(s.Lhs) == 1 && len(s.Rhs) == 1 {
+		index, ok := s.Lhs[0].(*ast.IndexExpr)
+		if !ok || !c.isSet(c.TypeOf(index.X)) {
+			return nil
+		}
+		add := &py.Call{
+			Func: &py.Attribute{Value: c.compileExpr(index.X), Attr: py.Identifier("add")},
+			Args: []py.Expr{c.compileExpr(index.Index)},
+		}
+		return &py.ExprStmt{Value: add}
+	}
+	if len(s.Lhs) == 2 && len(s.Rhs) == 1 && c.isBlank(s.Lhs[0]) {
+		index, ok := s.Rhs[0].(*ast.IndexExpr)
+		if !ok || !c.isSet(c.TypeOf(index.X)) {
+			return nil
+		}
+		return &py.Assign{
+			Targets: []py.Expr{c.compileTarget(s.Lhs[1])},
+			Value:   c.compileSetContains(index),
+		}
+	}
+	return nil
+}
+
 func (c *Compiler) compileAssignStmt(s *ast.AssignStmt) []py.Stmt {
 	e := c.exprCompiler()
+	if stmt := e.compileSetAssign(s); stmt != nil {
+		return append(e.stmts, stmt)
+	}
 	var stmt py.Stmt
 	if s.Tok == token.ASSIGN || s.Tok == token.DEFINE {
 		stmt = &py.Assign{
-			Targets: e.compileExprs(s.Lhs),
+			Targets: e.compileTargets(s.Lhs),
 			Value:   e.compileExprsTuple(s.Rhs),
 		}
 	} else if s.Tok == token.AND_NOT_ASSIGN { // x &^= y becomes x &= ~y
-		stmt = &py.AugAssign{
-			Target: e.compileExpr(s.Lhs[0]),
-			Value: &py.UnaryOpExpr{
-				Op:      py.Invert,
-				Operand: e.compileExpr(s.Rhs[0]),
-			},
-			Op: py.BitAnd,
-		}
+		stmt = e.compileAugAssign(s.Lhs[0], py.BitAnd, &py.UnaryOpExpr{
+			Op:      py.Invert,
+			Operand: e.compileExpr(s.Rhs[0]),
+		})
 	} else {
-		stmt = &py.AugAssign{
-			Target: e.compileExpr(s.Lhs[0]),
-			Value:  e.compileExpr(s.Rhs[0]),
-			Op:     c.augmentedOp(s.Tok),
-		}
+		stmt = e.compileAugAssign(s.Lhs[0], c.augmentedOp(s.Tok), e.compileExpr(s.Rhs[0]))
 	}
 	return append(e.stmts, stmt)
 }
@@ -205,23 +348,25 @@ func (c *Compiler) compileSwitchStmt(s *ast.SwitchStmt) []py.Stmt {
 	}
 	var tag py.Expr
 	if s.Tag != nil {
-		tag = &py.Name{Id: py.Identifier("tag")}
+		tag = &py.Name{Id: c.tempID("tag")}
 		assignTag := &py.Assign{Targets: []py.Expr{tag}, Value: e.compileExpr(s.Tag)}
 		stmts = append(stmts, assignTag)
 	}
 
+	continued, outerFlag := c.enterSwitch(s.Body)
 	var firstIfStmt *py.If
 	var lastIfStmt *py.If
 	var defaultBody []py.Stmt
-	for _, stmt := range s.Body.List {
+	for i, stmt := range s.Body.List {
 		caseClause := stmt.(*ast.CaseClause)
 		test := e.compileCaseClauseTest(caseClause, tag)
+		body := trimBreak(caseBody(s.Body.List, i))
 		if test == nil {
 			// no test => default clause
-			defaultBody = c.compileStmts(caseClause.Body)
+			defaultBody = c.compileStmts(body)
 			continue
 		}
-		ifStmt := &py.If{Test: test, Body: c.compileStmts(caseClause.Body)}
+		ifStmt := &py.If{Test: test, Body: c.compileStmts(body)}
 		if firstIfStmt == nil {
 			firstIfStmt = ifStmt
 			lastIfStmt = ifStmt
@@ -230,15 +375,17 @@ func (c *Compiler) compileSwitchStmt(s *ast.SwitchStmt) []py.Stmt {
 			lastIfStmt = ifStmt
 		}
 	}
+	c.continueFlag = outerFlag
 	stmts = append(stmts, e.stmts...)
+	var chain []py.Stmt
 	if lastIfStmt != nil {
 		lastIfStmt.Orelse = defaultBody
-		stmts = append(stmts, firstIfStmt)
+		chain = []py.Stmt{firstIfStmt}
 	} else {
 		// no cases apart from default
-		stmts = append(stmts, defaultBody...)
+		chain = defaultBody
 	}
-	return stmts
+	return append(stmts, c.compileSwitchLoop(s.Body, chain, continued)...)
 }
 
 func (c *Compiler) compileTypeSwitchStmt(s *ast.TypeSwitchStmt) []py.Stmt {
@@ -265,26 +412,36 @@ func (c *Compiler) compileTypeSwitchStmt(s *ast.TypeSwitchStmt) []py.Stmt {
 		panic(c.err(s, "Unknown statement type in type switch assign: %T", s))
 	}
 	expr := typeAssert.(*ast.TypeAssertExpr).X
-	tagValue := &py.Call{Func: pyType, Args: []py.Expr{e.compileExpr(expr)}}
+	var value py.Expr
+	if symbolicVarName != "" {
+		// The value is bound to the symbolic variable in each case
+		var valueStmts []py.Stmt
+		value, valueStmts = c.evaluateOnce(expr, "value")
+		stmts = append(stmts, valueStmts...)
+	} else {
+		value = e.compileExpr(expr)
+	}
+	tagValue := &py.Call{Func: pyType, Args: []py.Expr{value}}
 	assignTag := &py.Assign{Targets: []py.Expr{tag}, Value: tagValue}
 	stmts = append(stmts, assignTag)
 
+	continued, outerFlag := c.enterSwitch(s.Body)
 	var firstIfStmt *py.If
 	var lastIfStmt *py.If
 	var defaultBody []py.Stmt
 	for _, stmt := range s.Body.List {
 		caseClause := stmt.(*ast.CaseClause)
-		test := e.compileCaseClauseTest(caseClause, tag)
+		test := e.compileTypeCaseTest(caseClause, tag, value)
 		var bodyStmts []py.Stmt
 		if symbolicVarName != "" {
 			typedIdent := c.objID(c.Implicits[caseClause])
 			assign := &py.Assign{
 				Targets: []py.Expr{&py.Name{Id: typedIdent}},
-				Value:   tag,
+				Value:   value,
 			}
 			bodyStmts = append(bodyStmts, assign)
 		}
-		bodyStmts = append(bodyStmts, c.compileStmts(caseClause.Body)...)
+		bodyStmts = append(bodyStmts, c.compileStmts(trimBreak(caseClause.Body))...)
 		if test == nil {
 			// no test => default clause
 			defaultBody = bodyStmts
@@ -299,15 +456,59 @@ func (c *Compiler) compileTypeSwitchStmt(s *ast.TypeSwitchStmt) []py.Stmt {
 			lastIfStmt = ifStmt
 		}
 	}
+	c.continueFlag = outerFlag
 	stmts = append(stmts, e.stmts...)
+	var chain []py.Stmt
 	if lastIfStmt != nil {
 		lastIfStmt.Orelse = defaultBody
-		stmts = append(stmts, firstIfStmt)
+		chain = []py.Stmt{firstIfStmt}
 	} else {
 		// no cases apart from default
-		stmts = append(stmts, defaultBody...)
+		chain = defaultBody
 	}
-	return stmts
+	return append(stmts, c.compileSwitchLoop(s.Body, chain, continued)...)
+}
+
+// compileTypeCaseTest compiles the test of a case of a type switch on
+// value, whose type is tag. A case of an interface type tests whether the
+// value's class is a subclass of the interface's class:
+// isinstance(value, Shape)
+func (c *exprCompiler) compileTypeCaseTest(caseClause *ast.CaseClause, tag, value py.Expr) py.Expr {
+	var tests []py.Expr
+	for _, expr := range caseClause.List {
+		if c.Types[expr].IsNil() {
+			tests = append(tests, &py.Compare{Left: value, Ops: []py.CmpOp{py.Is}, Comparators: []py.Expr{pyNone}})
+			continue
+		}
+		iface, ok := c.TypeOf(expr).Underlying().(*types.Interface)
+		if !ok {
+			tests = append(tests, &py.Compare{
+				Left:        tag,
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{c.compileExpr(expr)},
+			})
+			continue
+		}
+		var test py.Expr
+		switch named, _ := c.TypeOf(expr).(*types.Named); {
+		case iface.NumMethods() == 0:
+			// Every value but nil implements the empty interface
+			test = &py.Compare{Left: value, Ops: []py.CmpOp{py.IsNot}, Comparators: []py.Expr{pyNone}}
+		case c.isError(c.TypeOf(expr)) && iface.NumMethods() == 1:
+			test = &py.Call{Func: pyIsInstance, Args: []py.Expr{value, pyException}}
+		case named != nil && named.Obj().Pkg() != nil && !compiledPackages[named.Obj().Pkg().Path()]:
+			test = &py.Call{Func: pyIsInstance, Args: []py.Expr{value, c.compileExpr(expr)}}
+		default:
+			panic(c.err(expr, "type switch case %s is not supported", types.ExprString(expr)))
+		}
+		tests = append(tests, test)
+	}
+	if len(tests) == 0 {
+		return nil
+	} else if len(tests) == 1 {
+		return tests[0]
+	}
+	return &py.BoolOpExpr{Op: py.Or, Values: tests}
 }
 
 func (c *Compiler) compileIfStmt(s *ast.IfStmt) []py.Stmt {
@@ -321,30 +522,83 @@ func (c *Compiler) compileIfStmt(s *ast.IfStmt) []py.Stmt {
 		ifStmt.Orelse = c.compileStmt(s.Else)
 	}
 	stmts = append(stmts, e.stmts...)
+	if c.Options.ConditionalExpressions {
+		if assign := conditionalAssign(ifStmt); assign != nil {
+			return append(stmts, assign)
+		}
+	}
 	stmts = append(stmts, ifStmt)
 	return stmts
 }
 
+// conditionalAssign returns the assignment of a conditional expression equivalent
+// to ifStmt if both its branches are a single assignment to the same variable,
+// otherwise nil.
+func conditionalAssign(ifStmt *py.If) *py.Assign {
+	if len(ifStmt.Body) != 1 || len(ifStmt.Orelse) != 1 {
+		return nil
+	}
+	body, ok := ifStmt.Body[0].(*py.Assign)
+	if !ok || len(body.Targets) != 1 {
+		return nil
+	}
+	orelse, ok := ifStmt.Orelse[0].(*py.Assign)
+	if !ok || len(orelse.Targets) != 1 {
+		return nil
+	}
+	bodyTarget, ok := body.Targets[0].(*py.Name)
+	if !ok {
+		return nil
+	}
+	orelseTarget, ok := orelse.Targets[0].(*py.Name)
+	if !ok || bodyTarget.Id != orelseTarget.Id {
+		return nil
+	}
+	return &py.Assign{
+		Targets: body.Targets,
+		Value:   &py.IfExp{Test: ifStmt.Test, Body: body.Value, Orelse: orelse.Value},
+	}
+}
+
 func (c *Compiler) compileBranchStmt(s *ast.BranchStmt) []py.Stmt {
 	switch s.Tok {
 	case token.BREAK:
+		if s.Label != nil {
+			label := c.ObjectOf(s.Label).(*types.Label)
+			if flag, ok := c.breakFlags[label]; ok && c.loops[len(c.loops)-1] != label {
+				return []py.Stmt{&py.Assign{Targets: []py.Expr{flag}, Value: pyTrue}, &py.Break{}}
+			}
+		}
 		return []py.Stmt{&py.Break{}}
 	case token.CONTINUE:
-		return []py.Stmt{&py.Continue{}}
-	case token.FALLTHROUGH:
-		return []py.Stmt{&py.ExprStmt{Value: &py.Call{Func: &py.Name{Id: py.Identifier("_TODO_fallthrough")}}}}
+		if s.Label != nil {
+			label := c.ObjectOf(s.Label).(*types.Label)
+			if flag, ok := c.continueFlags[label]; ok && c.loops[len(c.loops)-1] != label {
+				return []py.Stmt{&py.Assign{Targets: []py.Expr{flag}, Value: pyTrue}, &py.Break{}}
+			}
+		}
+		return c.compileContinue()
+	case token.GOTO:
+		block := &py.Num{N: strconv.Itoa(c.gotoBlocks[s.Label.Name])}
+		return []py.Stmt{&py.Assign{Targets: []py.Expr{c.gotoVar}, Value: block}, &py.Continue{}}
 	default:
 		panic(c.err(s, "unknown BranchStmt %v", s.Tok))
 	}
 }
 
+// compileContinue compiles a continue of the innermost loop.
+// The post statement of a for loop runs before the next iteration, and a
+// switch that compiles to a loop is left first.
+func (c *Compiler) compileContinue() []py.Stmt {
+	if c.continueFlag != nil {
+		return []py.Stmt{&py.Assign{Targets: []py.Expr{c.continueFlag}, Value: pyTrue}, &py.Break{}}
+	}
+	return append(append([]py.Stmt{}, c.continuePost...), &py.Continue{})
+}
+
 func (c *Compiler) compileForStmt(s *ast.ForStmt) []py.Stmt {
 	e := c.exprCompiler()
 	var stmts []py.Stmt
-	body := c.compileStmt(s.Body)
-	if s.Post != nil {
-		body = append(c.compileStmt(s.Body), c.compileStmt(s.Post)...)
-	}
 	if s.Init != nil {
 		stmts = c.compileStmt(s.Init)
 	}
@@ -352,12 +606,31 @@ func (c *Compiler) compileForStmt(s *ast.ForStmt) []py.Stmt {
 	if s.Cond != nil {
 		test = e.compileExpr(s.Cond)
 	}
+	var post []py.Stmt
+	if s.Post != nil {
+		post = c.compileStmt(s.Post)
+	}
+	outerPost, outerFlag := c.continuePost, c.continueFlag
+	c.continuePost, c.continueFlag = post, nil
+	body := c.compileStmt(s.Body)
+	c.continuePost, c.continueFlag = outerPost, outerFlag
+	body = append(body, post...)
+
+	if len(e.stmts) > 0 {
+		// The statements evaluating the condition must run on every iteration
+		// while True: <stmts>; if not <test>: break; <body>
+		exit := &py.If{
+			Test: &py.UnaryOpExpr{Op: py.Not, Operand: test},
+			Body: []py.Stmt{&py.Break{}},
+		}
+		body = append(append(e.stmts, exit), body...)
+		test = pyTrue
+	}
 
 	if len(body) == 0 {
 		body = []py.Stmt{&py.Pass{}}
 	}
 
-	stmts = append(stmts, e.stmts...)
 	stmts = append(stmts, &py.While{Test: test, Body: body})
 	return stmts
 }
@@ -371,13 +644,20 @@ func (c *Compiler) compileExprToStmt(e ast.Expr) []py.Stmt {
 		case *ast.Ident:
 			switch fun.Name {
 			case "delete":
+				if c.isSet(c.TypeOf(e.Args[0])) {
+					stmt = &py.ExprStmt{Value: &py.Call{
+						Func: &py.Attribute{Value: orEmptySet(ec.compileExpr(e.Args[0])), Attr: py.Identifier("discard")},
+						Args: []py.Expr{ec.compileExpr(e.Args[1])},
+					}}
+					break
+				}
 				stmt = &py.Try{
 					Body: []py.Stmt{
 						&py.Delete{
 							Targets: []py.Expr{
 								&py.Subscript{
-									Value: ec.compileExpr(e.Args[0]),
-									Slice: &py.Index{ec.compileExpr(e.Args[1])},
+									Value: orEmptyMap(ec.compileExpr(e.Args[0])),
+									Slice: &py.Index{Value: ec.compileExpr(e.Args[1])},
 								},
 							},
 						},
@@ -389,6 +669,12 @@ func (c *Compiler) compileExprToStmt(e ast.Expr) []py.Stmt {
 						},
 					},
 				}
+			case "copy":
+				stmt, _ = ec.compileCopy(e)
+			case "close":
+				stmt = &py.ExprStmt{Value: &py.Call{Func: closeMethod(ec.compileExpr(e.Args[0]))}}
+			case "panic":
+				stmt = &py.Raise{Exc: &py.Call{Func: goPanic, Args: []py.Expr{ec.compileExpr(e.Args[0])}}}
 			}
 		}
 	}
@@ -400,6 +686,27 @@ func (c *Compiler) compileExprToStmt(e ast.Expr) []py.Stmt {
 
 func (c *Compiler) compileReturnStmt(s *ast.ReturnStmt) []py.Stmt {
 	e := c.exprCompiler()
+	if c.results != nil {
+		// The deferred functions may set the named results, so they are
+		// assigned and the functions called before they are returned:
+		// x = 3; <call defers>; return x
+		var stmts []py.Stmt
+		results := make([]py.Expr, len(c.results))
+		for i, name := range c.results {
+			if name.Name == "_" && len(s.Results) == 0 {
+				results[i] = c.zeroValue(c.TypeOf(name))
+			} else if name.Name == "_" {
+				results[i] = &py.Name{Id: c.tempID("result")}
+			} else {
+				results[i] = &py.Name{Id: c.identifier(name)}
+			}
+		}
+		if len(s.Results) > 0 {
+			value := e.compileExprsTuple(s.Results)
+			stmts = append(e.stmts, &py.Assign{Targets: []py.Expr{makeTuple(results...)}, Value: value})
+		}
+		return append(stmts, c.callDefers(), &py.Return{Value: makeTuple(results...)})
+	}
 	stmt := &py.Return{Value: e.compileExprsTuple(s.Results)}
 	return append(e.stmts, stmt)
 }
@@ -427,15 +734,504 @@ func appendToList(list py.Expr, item py.Expr) py.Stmt {
 	}
 }
 
+// closeMethod returns the method of channel ch that closes it.
+func closeMethod(ch py.Expr) py.Expr {
+	return &py.Attribute{Value: ch, Attr: py.Identifier("close")}
+}
+
 func (c *Compiler) compileDeferStmt(s *ast.DeferStmt) []py.Stmt {
 	e := c.exprCompiler()
+	if ident, ok := s.Call.Fun.(*ast.Ident); ok && c.ObjectOf(ident) == builtin.close {
+		// The channel is evaluated now and closed by its bound close method
+		call := makeTuple(closeMethod(e.compileExpr(s.Call.Args[0])), &py.Tuple{})
+		return append(e.stmts, appendToList(c.defers, call))
+	}
+	// A method value compiles to a bound method, which captures the receiver now
 	f := e.compileExpr(s.Call.Fun)
 	args := &py.Tuple{Elts: e.compileExprs(s.Call.Args)}
 	return append(e.stmts, appendToList(c.defers, makeTuple(f, args)))
 }
 
+// compileGoStmt compiles go f(x) to a thread that calls f with the
+// arguments evaluated now:
+// runtime.go(f, x)
+func (c *Compiler) compileGoStmt(s *ast.GoStmt) []py.Stmt {
+	e := c.exprCompiler()
+	f := e.compileExpr(s.Call.Fun)
+	args := append([]py.Expr{f}, e.compileExprs(s.Call.Args)...)
+	return append(e.stmts, &py.ExprStmt{Value: &py.Call{Func: goStart, Args: args}})
+}
+
+// compileSendStmt compiles ch <- v to a send that waits for a receiver, or
+// for room in the channel's buffer:
+// ch.send(v)
+func (c *Compiler) compileSendStmt(s *ast.SendStmt) []py.Stmt {
+	e := c.exprCompiler()
+	send := &py.Call{
+		Func: &py.Attribute{Value: e.compileExpr(s.Chan), Attr: py.Identifier("send")},
+		Args: []py.Expr{e.compileExpr(s.Value)},
+	}
+	return append(e.stmts, &py.ExprStmt{Value: send})
+}
+
+// evaluateOnce returns an expression that can be evaluated repeatedly without
+// re-evaluating expr, together with any statements needed to evaluate it first.
+func (c *Compiler) evaluateOnce(expr ast.Expr, baseID string) (py.Expr, []py.Stmt) {
+	e := c.exprCompiler()
+	pyExpr := e.compileExpr(expr)
+	switch pyExpr.(type) {
+	case *py.Name, *py.Num, *py.Str, *py.NameConstant:
+		return pyExpr, e.stmts
+	}
+	temp := &py.Name{Id: c.tempID(baseID)}
+	return temp, append(e.stmts, &py.Assign{Targets: []py.Expr{temp}, Value: pyExpr})
+}
+
+// hasBranch reports whether stmts contain an unlabeled break or continue
+// statement that would apply to a statement enclosing them.
+func hasBranch(stmts []ast.Stmt, tok token.Token) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.BranchStmt:
+				found = found || (n.Tok == tok && n.Label == nil)
+			case *ast.ForStmt, *ast.RangeStmt, *ast.FuncLit:
+				return false
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				// These are break targets but not continue targets
+				return tok == token.CONTINUE
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// appendBreak appends a break statement to body unless it already ends with one.
+func appendBreak(body []py.Stmt) []py.Stmt {
+	if len(body) > 0 {
+		if _, ok := body[len(body)-1].(*py.Break); ok {
+			return body
+		}
+	}
+	return append(body, &py.Break{})
+}
+
+// caseBody returns the statements that run when the i'th case of clauses
+// is chosen: its body and, while a body ends in fallthrough, the body of the
+// case that follows it.
+func caseBody(clauses []ast.Stmt, i int) []ast.Stmt {
+	body := clauses[i].(*ast.CaseClause).Body
+	if len(body) > 0 {
+		if branch, ok := body[len(body)-1].(*ast.BranchStmt); ok && branch.Tok == token.FALLTHROUGH {
+			return append(body[:len(body)-1:len(body)-1], caseBody(clauses, i+1)...)
+		}
+	}
+	return body
+}
+
+// trimBreak returns the statements of a case body without a break that ends
+// it, which only leaves the switch as the end of the case does.
+func trimBreak(stmts []ast.Stmt) []ast.Stmt {
+	if len(stmts) > 0 {
+		if branch, ok := stmts[len(stmts)-1].(*ast.BranchStmt); ok && branch.Tok == token.BREAK && branch.Label == nil {
+			return stmts[:len(stmts)-1]
+		}
+	}
+	return stmts
+}
+
+// isSwitchLoop reports whether a switch statement with body compiles to a
+// loop, which it does when a case breaks out of the switch before its end.
+func isSwitchLoop(body *ast.BlockStmt) bool {
+	for _, stmt := range body.List {
+		if hasBranch(trimBreak(stmt.(*ast.CaseClause).Body), token.BREAK) {
+			return true
+		}
+	}
+	return false
+}
+
+// enterSwitch starts compiling the cases of a switch statement with body.
+// If the switch compiles to a loop and a case continues the enclosing loop,
+// it returns the flag that the continue sets to continue after the switch.
+// It also returns the flag of the enclosing switch to restore afterwards.
+func (c *Compiler) enterSwitch(body *ast.BlockStmt) (continued, outer *py.Name) {
+	outer = c.continueFlag
+	if isSwitchLoop(body) && hasBranch(body.List, token.CONTINUE) {
+		continued = &py.Name{Id: c.tempID("continued")}
+		c.continueFlag = continued
+	}
+	return continued, outer
+}
+
+// compileSwitchLoop compiles chain, the if/elif chain of the cases of a
+// switch statement with body, in a loop that runs once if a case breaks out
+// of the switch, so that the break leaves the loop:
+// while True: <chain>; break
+// A continue inside sets the flag continued and breaks, so the loop is
+// followed by
+// if continued: continue
+func (c *Compiler) compileSwitchLoop(body *ast.BlockStmt, chain []py.Stmt, continued *py.Name) []py.Stmt {
+	if !isSwitchLoop(body) {
+		return chain
+	}
+	var stmts []py.Stmt
+	if continued != nil {
+		stmts = append(stmts, &py.Assign{Targets: []py.Expr{continued}, Value: pyFalse})
+	}
+	stmts = append(stmts, &py.While{Test: pyTrue, Body: appendBreak(chain)})
+	if continued != nil {
+		stmts = append(stmts, &py.If{Test: continued, Body: c.compileContinue()})
+	}
+	return stmts
+}
+
+// isSelectLoop reports whether a select statement compiles to a loop.
+// A blocking select polls its cases until one is ready, and a break in a
+// case body must leave the select, so both need to be inside a loop.
+func isSelectLoop(s *ast.SelectStmt) bool {
+	return !hasDefault(s) || hasBranch(s.Body.List, token.BREAK)
+}
+
+// hasDefault reports whether the select statement s has a default case.
+func hasDefault(s *ast.SelectStmt) bool {
+	for _, stmt := range s.Body.List {
+		if stmt.(*ast.CommClause).Comm == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoop reports whether stmt compiles to a Python loop.
+func isLoop(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ForStmt, *ast.RangeStmt:
+		return true
+	case *ast.SelectStmt:
+		return isSelectLoop(s)
+	case *ast.SwitchStmt:
+		return isSwitchLoop(s.Body)
+	case *ast.TypeSwitchStmt:
+		return isSwitchLoop(s.Body)
+	}
+	return false
+}
+
+// hasLabeledBranch reports whether stmts contain a break or continue, as
+// tok says, to label, only counting those inside loops nested in stmts if
+// nested is true.
+func (c *Compiler) hasLabeledBranch(stmts []ast.Stmt, tok token.Token, label *types.Label, nested bool) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.BranchStmt:
+				found = found || (n.Tok == tok && n.Label != nil &&
+					c.ObjectOf(n.Label) == label && !nested)
+			case *ast.FuncLit:
+				return false
+			case ast.Stmt:
+				if nested && n != stmt && isLoop(n) {
+					found = found || c.hasLabeledBranch([]ast.Stmt{n}, tok, label, false)
+					return false
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// gotoTargets returns the labels of the statements of body that a goto in
+// body jumps to.
+func gotoTargets(body *ast.BlockStmt) map[string]bool {
+	targets := map[string]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			// The labels of a function literal are its own
+			return false
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO {
+				targets[n.Label.Name] = true
+			}
+		}
+		return true
+	})
+	return targets
+}
+
+// unsupportedGotos returns the gotos in the function body that cannot be
+// compiled: those to a label that is not on a statement of body itself,
+// and those inside a statement that compiles to a loop, which would
+// continue that loop instead of the dispatch loop.
+func unsupportedGotos(body *ast.BlockStmt) []*ast.BranchStmt {
+	labels := map[string]bool{}
+	for _, stmt := range body.List {
+		if labeled, ok := stmt.(*ast.LabeledStmt); ok {
+			labels[labeled.Label.Name] = true
+		}
+	}
+	var unsupported []*ast.BranchStmt
+	var visit func(node ast.Node, inLoop bool)
+	visit = func(node ast.Node, inLoop bool) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BranchStmt:
+				if n.Tok == token.GOTO && (inLoop || !labels[n.Label.Name]) {
+					unsupported = append(unsupported, n)
+				}
+			case ast.Stmt:
+				if !inLoop && isLoop(n) {
+					visit(n, true)
+					return false
+				}
+			}
+			return true
+		})
+	}
+	visit(body, false)
+	return unsupported
+}
+
+// compileFuncBody compiles the statements of a function body. If body has
+// gotos, its statements are split into blocks that start at the labels the
+// gotos jump to, and run in a dispatch loop:
+// goto = 0
+// while True:
+//
+//	if goto <= 0: <statements before the first label>
+//	if goto <= 1: <statements from the first label>
+//	...
+//	break
+//
+// Each block runs on into the next, and goto L compiles to
+// goto = <block of L>; continue
+func (c *Compiler) compileFuncBody(body *ast.BlockStmt) []py.Stmt {
+	var stmts []py.Stmt
+	targets := gotoTargets(body)
+	if len(targets) == 0 {
+		for _, stmt := range body.List {
+			stmts = append(stmts, c.compileStmt(stmt)...)
+		}
+		return stmts
+	}
+	if unsupported := unsupportedGotos(body); len(unsupported) > 0 {
+		panic(c.err(unsupported[0], "goto out of a loop or into a block"))
+	}
+	c.gotoVar = &py.Name{Id: c.tempID("goto")}
+	c.gotoBlocks = map[string]int{}
+	blocks := [][]ast.Stmt{nil}
+	for _, stmt := range body.List {
+		if labeled, ok := stmt.(*ast.LabeledStmt); ok && targets[labeled.Label.Name] {
+			c.gotoBlocks[labeled.Label.Name] = len(blocks)
+			blocks = append(blocks, nil)
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], stmt)
+	}
+	var dispatch []py.Stmt
+	for i, block := range blocks {
+		if block == nil {
+			continue
+		}
+		var blockStmts []py.Stmt
+		for _, stmt := range block {
+			blockStmts = append(blockStmts, c.compileStmt(stmt)...)
+		}
+		dispatch = append(dispatch, &py.If{
+			Test: &py.Compare{
+				Left:        c.gotoVar,
+				Ops:         []py.CmpOp{py.LtE},
+				Comparators: []py.Expr{&py.Num{N: strconv.Itoa(i)}},
+			},
+			Body: blockStmts,
+		})
+	}
+	return []py.Stmt{
+		&py.Assign{Targets: []py.Expr{c.gotoVar}, Value: &py.Num{N: "0"}},
+		&py.While{Test: pyTrue, Body: append(dispatch, &py.Break{})},
+	}
+}
+
+// compileLabeledStmt compiles a labeled statement.
+// A break or continue to the label of a loop from inside a nested loop sets
+// a flag that each nested loop checks after it finishes, to break the next
+// loop out until the labeled loop is reached.
+func (c *Compiler) compileLabeledStmt(s *ast.LabeledStmt) []py.Stmt {
+	label := c.ObjectOf(s.Label).(*types.Label)
+	var stmts []py.Stmt
+	if isLoop(s.Stmt) {
+		c.labels[s.Stmt] = label
+		if c.hasLabeledBranch([]ast.Stmt{s.Stmt}, token.BREAK, label, true) {
+			flag := &py.Name{Id: c.tempID("break" + label.Name())}
+			c.breakFlags[label] = flag
+			stmts = append(stmts, &py.Assign{Targets: []py.Expr{flag}, Value: pyFalse})
+		}
+		if c.hasLabeledBranch([]ast.Stmt{s.Stmt}, token.CONTINUE, label, true) {
+			flag := &py.Name{Id: c.tempID("continue" + label.Name())}
+			c.continueFlags[label] = flag
+			stmts = append(stmts, &py.Assign{Targets: []py.Expr{flag}, Value: pyFalse})
+		}
+	}
+	return append(stmts, c.compileStmt(s.Stmt)...)
+}
+
+// compileBreakChecks compiles the checks after the loop that stmt compiles
+// to of the flags set by breaks and continues inside it to the enclosing
+// loops. The loop a continue is to clears its flag and continues.
+func (c *Compiler) compileBreakChecks(stmt ast.Stmt) []py.Stmt {
+	var stmts []py.Stmt
+	for i, label := range c.loops {
+		if flag, ok := c.breakFlags[label]; ok && c.hasLabeledBranch([]ast.Stmt{stmt}, token.BREAK, label, false) {
+			stmts = append(stmts, &py.If{Test: flag, Body: []py.Stmt{&py.Break{}}})
+		}
+		if flag, ok := c.continueFlags[label]; ok && c.hasLabeledBranch([]ast.Stmt{stmt}, token.CONTINUE, label, false) {
+			body := []py.Stmt{&py.Break{}}
+			if i == len(c.loops)-1 {
+				body = append([]py.Stmt{&py.Assign{Targets: []py.Expr{flag}, Value: pyFalse}}, c.compileContinue()...)
+			}
+			stmts = append(stmts, &py.If{Test: flag, Body: body})
+		}
+	}
+	return stmts
+}
+
+func (c *Compiler) compileSelectStmt(s *ast.SelectStmt) []py.Stmt {
+	var stmts []py.Stmt
+	var cases []*py.If
+	// The statements before the test of each case
+	var tries [][]py.Stmt
+	var defaultBody []py.Stmt
+	hasDefault := false
+	loop := isSelectLoop(s)
+	// A continue inside a select that compiles to a loop leaves it first
+	var continued *py.Name
+	outerFlag := c.continueFlag
+	if loop && hasBranch(s.Body.List, token.CONTINUE) {
+		continued = &py.Name{Id: c.tempID("continued")}
+		c.continueFlag = continued
+	}
+	for _, stmt := range s.Body.List {
+		clause := stmt.(*ast.CommClause)
+		body := c.compileStmts(clause.Body)
+		var recvExpr ast.Expr
+		var recvTargets []ast.Expr
+		switch comm := clause.Comm.(type) {
+		case nil:
+			hasDefault = true
+			defaultBody = body
+		case *ast.SendStmt:
+			// The channel and value are evaluated once on entering the select
+			ch, chStmts := c.evaluateOnce(comm.Chan, "chan")
+			value, valueStmts := c.evaluateOnce(comm.Value, "value")
+			stmts = append(stmts, chStmts...)
+			stmts = append(stmts, valueStmts...)
+			trySend := &py.Call{
+				Func: &py.Attribute{Value: ch, Attr: py.Identifier("trySend")},
+				Args: []py.Expr{value},
+			}
+			cases = append(cases, &py.If{Test: c.guardNilChan(comm.Chan, ch, trySend), Body: body})
+			tries = append(tries, nil)
+		case *ast.ExprStmt:
+			recvExpr = comm.X
+		case *ast.AssignStmt:
+			recvExpr = comm.Rhs[0]
+			recvTargets = comm.Lhs
+		default:
+			panic(c.err(clause, "unsupported select case: %T", comm))
+		}
+		if recvExpr != nil {
+			// recv = ch is not None and ch.tryRecv()
+			// if recv:
+			//     v, ok = recv
+			//     ...
+			// else: <the next cases>
+			unary, ok := ast.Unparen(recvExpr).(*ast.UnaryExpr)
+			if !ok || unary.Op != token.ARROW {
+				panic(c.err(clause, "unsupported select case: %T", recvExpr))
+			}
+			ch, chStmts := c.evaluateOnce(unary.X, "chan")
+			stmts = append(stmts, chStmts...)
+			recv := &py.Name{Id: c.tempID("recv")}
+			tryRecv := &py.Assign{
+				Targets: []py.Expr{recv},
+				Value:   c.guardNilChan(unary.X, ch, &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("tryRecv")}}),
+			}
+			if len(recvTargets) > 0 {
+				e := c.exprCompiler()
+				targets := e.compileTargets(recvTargets)
+				if len(targets) == 1 {
+					targets = append(targets, &py.Name{Id: py.Identifier("_")})
+				}
+				assign := &py.Assign{Targets: []py.Expr{&py.Tuple{Elts: targets}}, Value: recv}
+				body = append(append(e.stmts, assign), body...)
+			}
+			cases = append(cases, &py.If{Test: recv, Body: body})
+			tries = append(tries, []py.Stmt{tryRecv})
+		}
+	}
+	c.continueFlag = outerFlag
+
+	if loop {
+		for _, ifStmt := range cases {
+			ifStmt.Body = appendBreak(ifStmt.Body)
+		}
+		if hasDefault {
+			defaultBody = appendBreak(defaultBody)
+		}
+	}
+	for _, ifStmt := range cases {
+		if len(ifStmt.Body) == 0 {
+			ifStmt.Body = []py.Stmt{&py.Pass{}}
+		}
+	}
+
+	// Chain the cases into if/elif/else
+	chain := defaultBody
+	for i := len(cases) - 1; i >= 0; i-- {
+		cases[i].Orelse = chain
+		chain = append(tries[i], cases[i])
+	}
+	if !loop {
+		return append(stmts, chain...)
+	}
+	if !hasDefault {
+		// No case was ready so let other threads run before polling again
+		gosched := &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Gosched")}}
+		chain = append(chain, &py.ExprStmt{Value: gosched})
+	}
+	if continued != nil {
+		stmts = append(stmts, &py.Assign{Targets: []py.Expr{continued}, Value: pyFalse})
+	}
+	stmts = append(stmts, &py.While{Test: pyTrue, Body: chain})
+	if continued != nil {
+		stmts = append(stmts, &py.If{Test: continued, Body: c.compileContinue()})
+	}
+	return stmts
+}
+
+// guardNilChan guards try, a send to or receive from ch, the compiled
+// channel expr, in a select, where a nil channel is never ready:
+// ch is not None and ch.tryRecv()
+func (c *Compiler) guardNilChan(expr ast.Expr, ch py.Expr, try py.Expr) py.Expr {
+	if !c.mayBeNil(expr) {
+		return try
+	}
+	isNotNone := &py.Compare{Left: ch, Ops: []py.CmpOp{py.IsNot}, Comparators: []py.Expr{pyNone}}
+	return &py.BoolOpExpr{Op: py.And, Values: []py.Expr{isNotNone, try}}
+}
+
 func (c *Compiler) compileStmt(stmt ast.Stmt) []py.Stmt {
 	var pyStmts []py.Stmt
+	loop := isLoop(stmt)
+	if loop {
+		c.loops = append(c.loops, c.labels[stmt])
+	}
 	switch s := stmt.(type) {
 	case *ast.ReturnStmt:
 		pyStmts = c.compileReturnStmt(s)
@@ -465,23 +1261,33 @@ func (c *Compiler) compileStmt(stmt ast.Stmt) []py.Stmt {
 		pyStmts = []py.Stmt{}
 	case *ast.DeferStmt:
 		pyStmts = c.compileDeferStmt(s)
+	case *ast.GoStmt:
+		pyStmts = c.compileGoStmt(s)
+	case *ast.SendStmt:
+		pyStmts = c.compileSendStmt(s)
+	case *ast.SelectStmt:
+		pyStmts = c.compileSelectStmt(s)
 	case *ast.LabeledStmt:
-		// TODO labels
-		pyStmts = c.compileStmt(s.Stmt)
+		pyStmts = c.compileLabeledStmt(s)
 	default:
 		panic(c.err(stmt, "unknown Stmt: %T", stmt))
 	}
+	if loop {
+		c.loops = c.loops[:len(c.loops)-1]
+		pyStmts = append(pyStmts, c.compileBreakChecks(stmt)...)
+	}
 
 	if c.commentMap != nil {
-		var commentStmts []py.Stmt
+		var commentStmts, trailingStmts []py.Stmt
 		for _, commentGroup := range (*c.commentMap)[stmt] {
-			text := commentGroup.Text()
-			text = strings.TrimRight(text, "\n")
-			for _, line := range strings.Split(text, "\n") {
-				commentStmts = append(commentStmts, &py.Comment{Text: " " + line})
+			if c.isTrailingComment(stmt, commentGroup) {
+				trailingStmts = append(trailingStmts, makeComments(commentGroup)...)
+			} else {
+				commentStmts = append(commentStmts, makeComments(commentGroup)...)
 			}
 		}
 		pyStmts = append(commentStmts, pyStmts...)
+		pyStmts = append(pyStmts, trailingStmts...)
 	}
 
 	return pyStmts
@@ -13,6 +13,13 @@ import (
 // Each test compiles this code with the expression under test substituted for %s
 const stmtPkgTemplate = `package main
 
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
 type T struct{x, y int}
 var (
 	t0 = T{}
@@ -21,21 +28,61 @@ var (
 
 type U struct{}
 
+type Sizer interface{ Size() int }
+
+type Counts map[string]int
+
+func (c Counts) Get(k string) int { return c[k] }
+
+type F func(int) int
+
+type V struct{ items []int }
+var v0 V
+
+type P struct {
+	x int
+	U
+	y int
+}
+
+type ID string
+const Root ID = "root"
+
+func takeID(ID) {}
+
+func next() *T { return nil }
+func cond() bool { return false }
+
 var (
 	b0, b1 bool
 	w, x, y, z int
 	u0, u1 uint
 	xs []int
+	arr [3]int
 	obj interface{}
 	m map[int]int
+	ms map[int][]int
+	set0 map[int]struct{}
+	ch chan int
+	str string
+	ok bool
+	i64 int64
+	u64 uint64
+	err error
 )
 
+var _, _, _, _ = fmt.Sprint, runtime.GOOS, strconv.Itoa, strings.ToUpper
+
 func ignore(interface{}) {}
 func f0() int { return 0 }
 func f1(int) int { return 0 }
 func f2(int, int) int { return 0 }
 
+const N, M = 2, 3
+
 func g2() (int, int) { return 0, 0 }
+func g3() (int, int, int) { return 0, 0, 0 }
+func ge() (int, error) { return 0, nil }
 
 func s(...interface{}) {}
 
@@ -53,6 +100,8 @@ var tag = &py.Name{Id: py.Identifier("tag")}
 var (
 	ax = &py.Name{Id: py.Identifier("ax")}
 	ay = &py.Name{Id: py.Identifier("ay")}
+	az = &py.Name{Id: py.Identifier("az")}
+	aw = &py.Name{Id: py.Identifier("aw")}
 )
 
 // Placeholders for statement blocks
@@ -76,16 +125,31 @@ func s(is ...interface{}) []py.Stmt {
 	return []py.Stmt{&py.ExprStmt{Value: &py.Call{Func: &py.Name{Id: py.Identifier("s")}, Args: args}}}
 }
 
+var (
+	counts   = &py.Name{Id: py.Identifier("Counts")}
+	sizer    = &py.Name{Id: py.Identifier("Sizer")}
+	chanName = &py.Name{Id: py.Identifier("chan")}
+	dstName  = &py.Name{Id: py.Identifier("dst")}
+	srcName  = &py.Name{Id: py.Identifier("src")}
+	nName    = &py.Name{Id: py.Identifier("n")}
+)
+
+// listOf returns list(x).
+func listOf(x py.Expr) py.Expr {
+	return &py.Call{Func: pyList, Args: []py.Expr{x}}
+}
+
+func lenCall(x py.Expr) py.Expr {
+	return &py.Call{Func: pyLen, Args: []py.Expr{x}}
+}
+
 var (
 	zero = &py.Num{N: "0"}
 	one  = &py.Num{N: "1"}
 	two  = &py.Num{N: "2"}
 )
 
-var stmtTests = []struct {
-	golang string
-	python []py.Stmt
-}{
+var stmtTests = []stmtTest{
 	// Empty statement
 	{";", []py.Stmt{}},
 
@@ -106,6 +170,18 @@ var stmtTests = []struct {
 		Targets: []py.Expr{x, y},
 		Value:   &py.Tuple{Elts: []py.Expr{y, x}},
 	}}},
+	// Calls in the targets are made before the call on the right as in Go
+	{"next().x, next().y = g2()", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("target")}}, Value: &py.Call{Func: next}},
+		&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("target1")}}, Value: &py.Call{Func: next}},
+		&py.Assign{
+			Targets: []py.Expr{
+				&py.Attribute{Value: &py.Name{Id: py.Identifier("target")}, Attr: py.Identifier("x")},
+				&py.Attribute{Value: &py.Name{Id: py.Identifier("target1")}, Attr: py.Identifier("y")},
+			},
+			Value: &py.Call{Func: g2},
+		},
+	}},
 
 	// Short variable declarations
 	{"ax := y; _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: y}}},
@@ -117,6 +193,116 @@ var stmtTests = []struct {
 		Targets: []py.Expr{ax, ay},
 		Value:   &py.Tuple{Elts: []py.Expr{y, x}},
 	}}},
+	// Blank identifiers are bound in Python but the call is still made
+	{"_, err := ge(); _ = err", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}, &py.Name{Id: py.Identifier("err")}},
+		Value:   &py.Call{Func: &py.Name{Id: py.Identifier("ge")}},
+	}}},
+	{"ax, _, ay := g3(); _, _ = ax, ay", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{ax, &py.Name{Id: py.Identifier("_")}, ay},
+		Value:   &py.Call{Func: &py.Name{Id: py.Identifier("g3")}},
+	}}},
+
+	// Map entries
+	{"x = m[y]", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x},
+		Value:   &py.Call{Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("get")}, Args: []py.Expr{y, zero}},
+	}}},
+	// Assigning to an entry in a nil map panics
+	{"m[x] = y", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Subscript{Value: writableMap(m), Slice: &py.Index{Value: x}}},
+		Value:   y,
+	}}},
+	// Updating a missing entry updates its zero value
+	{"m[x]++", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Subscript{Value: writableMap(m), Slice: &py.Index{Value: x}}},
+		Value: &py.BinOp{
+			Left:  &py.Call{Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("get")}, Args: []py.Expr{x, zero}},
+			Op:    py.Add,
+			Right: one,
+		},
+	}}},
+	{"m[x] -= y", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Subscript{Value: writableMap(m), Slice: &py.Index{Value: x}}},
+		Value: &py.BinOp{
+			Left:  &py.Call{Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("get")}, Args: []py.Expr{x, zero}},
+			Op:    py.Sub,
+			Right: y,
+		},
+	}}},
+	// Appending to a missing key appends to a nil slice
+	{"ms[x] = append(ms[x], y)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Subscript{Value: writableMap(ms), Slice: &py.Index{Value: x}}},
+		Value: &py.BinOp{
+			Left:  &py.Call{Func: &py.Attribute{Value: orEmptyMap(ms), Attr: py.Identifier("get")}, Args: []py.Expr{x, &py.List{}}},
+			Op:    py.Add,
+			Right: &py.List{Elts: []py.Expr{y}},
+		},
+	}}},
+	{"v0.items = append(v0.items, x)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Attribute{Value: v0, Attr: py.Identifier("items")}},
+		Value: &py.BinOp{
+			Left:  orEmptyList(&py.Attribute{Value: v0, Attr: py.Identifier("items")}),
+			Op:    py.Add,
+			Right: &py.List{Elts: []py.Expr{x}},
+		},
+	}}},
+	{"_ = append(xs, x)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Name{Id: py.Identifier("_")}},
+		Value:   &py.BinOp{Left: orEmptyList(xs), Op: py.Add, Right: &py.List{Elts: []py.Expr{x}}},
+	}}},
+	{"xs = append(xs, x)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{xs},
+		Value:   &py.BinOp{Left: orEmptyList(xs), Op: py.Add, Right: &py.List{Elts: []py.Expr{x}}},
+	}}},
+	{"xs = append(xs, x, y, z)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{xs},
+		Value:   &py.BinOp{Left: orEmptyList(xs), Op: py.Add, Right: &py.List{Elts: []py.Expr{x, y, z}}},
+	}}},
+	// A spread slice is copied into a list, and a nil slice is empty
+	{"xs = append(xs, xs...)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{xs},
+		Value:   &py.BinOp{Left: orEmptyList(xs), Op: py.Add, Right: &py.Call{Func: pyList, Args: []py.Expr{orEmptyList(xs)}}},
+	}}},
+	{"xs = append(xs[:1], arr[:]...)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{xs},
+		Value: &py.BinOp{
+			Left:  &py.Subscript{Value: xs, Slice: &py.RangeSlice{Upper: one}},
+			Op:    py.Add,
+			Right: &py.Call{Func: pyList, Args: []py.Expr{&py.Subscript{Value: arr, Slice: &py.RangeSlice{}}}},
+		},
+	}}},
+
+	// copy assigns the elements that fit and is the number of them, and a
+	// nil slice has none
+	{"copy(xs, arr[:])", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{dstName}, Value: orEmptyList(xs)},
+		&py.Assign{Targets: []py.Expr{srcName}, Value: &py.Subscript{Value: arr, Slice: &py.RangeSlice{}}},
+		&py.Assign{
+			Targets: []py.Expr{&py.Subscript{Value: dstName, Slice: &py.RangeSlice{Upper: lenCall(srcName)}}},
+			Value:   &py.Subscript{Value: srcName, Slice: &py.RangeSlice{Upper: lenCall(dstName)}},
+		},
+	}},
+	// A slice of xs is copied into through xs
+	{"x = copy(xs[1:], xs)", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{dstName}, Value: orEmptyList(xs)},
+		&py.Assign{Targets: []py.Expr{srcName}, Value: orEmptyList(xs)},
+		&py.Assign{
+			Targets: []py.Expr{nName},
+			Value: &py.Call{Func: pyMin, Args: []py.Expr{
+				lenCall(&py.Subscript{Value: dstName, Slice: &py.RangeSlice{Lower: one}}),
+				lenCall(srcName),
+			}},
+		},
+		&py.Assign{
+			Targets: []py.Expr{&py.Subscript{Value: dstName, Slice: &py.RangeSlice{
+				Lower: one,
+				Upper: &py.BinOp{Left: one, Op: py.Add, Right: nName},
+			}}},
+			Value: &py.Subscript{Value: srcName, Slice: &py.RangeSlice{Upper: nName}},
+		},
+		&py.Assign{Targets: []py.Expr{x}, Value: nName},
+	}},
 
 	// Augmented assignments
 	{"x +=  y", []py.Stmt{&py.AugAssign{Op: py.Add, Target: x, Value: y}}},
@@ -134,6 +320,23 @@ var stmtTests = []struct {
 	// Branch statements
 	{"for { break }", []py.Stmt{&py.While{Test: pyTrue, Body: []py.Stmt{&py.Break{}}}}},
 	{"for { continue }", []py.Stmt{&py.While{Test: pyTrue, Body: []py.Stmt{&py.Continue{}}}}},
+	// continue runs the post statement first, but break does not
+	{"for ; x < 10; x++ { if b0 { continue }; if b1 { break } }", []py.Stmt{&py.While{
+		Test: &py.Compare{Left: x, Ops: []py.CmpOp{py.Lt}, Comparators: []py.Expr{&py.Num{N: "10"}}},
+		Body: []py.Stmt{
+			&py.If{Test: b0, Body: []py.Stmt{&py.AugAssign{Target: x, Op: py.Add, Value: one}, &py.Continue{}}},
+			&py.If{Test: b1, Body: []py.Stmt{&py.Break{}}},
+			&py.AugAssign{Target: x, Op: py.Add, Value: one},
+		},
+	}}},
+	// A continue in a range loop inside the for loop continues the range loop
+	{"for ; x < 10; x++ { for range xs { continue } }", []py.Stmt{&py.While{
+		Test: &py.Compare{Left: x, Ops: []py.CmpOp{py.Lt}, Comparators: []py.Expr{&py.Num{N: "10"}}},
+		Body: []py.Stmt{
+			&py.For{Target: &py.Name{Id: py.Identifier("_")}, Iter: xs, Body: []py.Stmt{&py.Continue{}}},
+			&py.AugAssign{Target: x, Op: py.Add, Value: one},
+		},
+	}}},
 
 	// If statements
 	{"if b0 {s(0)}", []py.Stmt{&py.If{Test: b0, Body: s(0)}}},
@@ -190,6 +393,106 @@ var stmtTests = []struct {
 			Body:   []py.Stmt{&py.Pass{}},
 		},
 	}},
+	{"for x := range arr {s(x)}", []py.Stmt{
+		&py.For{
+			Target: x,
+			Iter:   &py.Call{Func: pyRange, Args: []py.Expr{&py.Call{Func: pyLen, Args: []py.Expr{arr}}}},
+			Body:   s(x),
+		},
+	}},
+	{"for x, y := range arr {s(x,y)}", []py.Stmt{
+		&py.For{
+			Target: &py.Tuple{Elts: []py.Expr{x, y}},
+			Iter:   &py.Call{Func: pyEnumerate, Args: []py.Expr{arr}},
+			Body:   s(x, y),
+		},
+	}},
+	// Ranging over a string gives the byte offset and code point of each rune
+	{"for x := range str {s(x)}", []py.Stmt{
+		&py.For{
+			Target: &py.Tuple{Elts: []py.Expr{x, &py.Name{Id: py.Identifier("_")}}},
+			Iter:   &py.Call{Func: goRunes, Args: []py.Expr{str}},
+			Body:   s(x),
+		},
+	}},
+	{"for x, r := range str {s(x,r)}", []py.Stmt{
+		&py.For{
+			Target: &py.Tuple{Elts: []py.Expr{x, r}},
+			Iter:   &py.Call{Func: goRunes, Args: []py.Expr{str}},
+			Body:   s(x, r),
+		},
+	}},
+	{"for _, r := range \"héllo\" {s(r)}", []py.Stmt{
+		&py.For{
+			Target: r,
+			Iter:   &py.Call{Func: pyMap, Args: []py.Expr{pyOrd, &py.Str{S: `"héllo"`}}},
+			Body:   s(r),
+		},
+	}},
+	{"for range str {}", []py.Stmt{
+		&py.For{Target: &py.Name{Id: py.Identifier("_")}, Iter: str, Body: []py.Stmt{&py.Pass{}}},
+	}},
+	// Ranging over an integer counts up to it
+	{"for x := range 10 {s(x)}", []py.Stmt{
+		&py.For{Target: x, Iter: &py.Call{Func: pyRange, Args: []py.Expr{&py.Num{N: "10"}}}, Body: s(x)},
+	}},
+	{"for x := range y {s(x)}", []py.Stmt{
+		&py.For{Target: x, Iter: &py.Call{Func: pyRange, Args: []py.Expr{y}}, Body: s(x)},
+	}},
+	{"for x := range y { if x == 3 { break }; s(x) }", []py.Stmt{
+		&py.For{
+			Target: x,
+			Iter:   &py.Call{Func: pyRange, Args: []py.Expr{y}},
+			Body: append([]py.Stmt{&py.If{
+				Test: &py.Compare{Left: x, Ops: []py.CmpOp{py.Eq}, Comparators: []py.Expr{&py.Num{N: "3"}}},
+				Body: []py.Stmt{&py.Break{}},
+			}}, s(x)...),
+		},
+	}},
+	{"for range y {s(0)}", []py.Stmt{
+		&py.For{Target: &py.Name{Id: py.Identifier("_")}, Iter: &py.Call{Func: pyRange, Args: []py.Expr{y}}, Body: s(0)},
+	}},
+	// Ranging over a map iterates a list of its keys and values, so that
+	// the loop can delete them, and a nil map is empty
+	{"for x := range m {s(x)}", []py.Stmt{
+		&py.For{Target: x, Iter: listOf(orEmptyMap(m)), Body: s(x)},
+	}},
+	{"for x, y := range m {s(x,y)}", []py.Stmt{
+		&py.For{
+			Target: &py.Tuple{Elts: []py.Expr{x, y}},
+			Iter:   listOf(&py.Call{Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("items")}}),
+			Body:   s(x, y),
+		},
+	}},
+	{"for _, y := range m {s(y)}", []py.Stmt{
+		&py.For{
+			Target: y,
+			Iter:   listOf(&py.Call{Func: &py.Attribute{Value: orEmptyMap(m), Attr: py.Identifier("values")}}),
+			Body:   s(y),
+		},
+	}},
+	{"for range m {}", []py.Stmt{
+		&py.For{Target: &py.Name{Id: py.Identifier("_")}, Iter: listOf(orEmptyMap(m)), Body: []py.Stmt{&py.Pass{}}},
+	}},
+	// Ranging over a channel receives until it is closed, and a nil channel
+	// would block forever so it raises an error
+	{"for range ch {}", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{chanName}, Value: &py.Call{Func: goRangeChan, Args: []py.Expr{ch}}},
+		&py.While{Test: pyTrue, Body: recvLoop(chanName)},
+	}},
+	{"for range ch {s(0)}", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{chanName}, Value: &py.Call{Func: goRangeChan, Args: []py.Expr{ch}}},
+		&py.While{Test: pyTrue, Body: append(recvLoop(chanName), s(0)...)},
+	}},
+	// The value is each value received
+	{"for x := range ch {s(x)}", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{chanName}, Value: &py.Call{Func: goRangeChan, Args: []py.Expr{ch}}},
+		&py.While{Test: pyTrue, Body: append(recvValueLoop(chanName, x), s(x)...)},
+	}},
+	{"for x := range make(chan int) {s(x)}", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{chanName}, Value: &py.Call{Func: goChan, Args: []py.Expr{zero, zero}}},
+		&py.While{Test: pyTrue, Body: append(recvValueLoop(chanName, x), s(x)...)},
+	}},
 
 	// For statement
 	{"for {s(0)}", []py.Stmt{
@@ -210,6 +513,32 @@ var stmtTests = []struct {
 			Body: []py.Stmt{&py.Pass{}},
 		},
 	}},
+	{"for ax := next(); ax != nil; ax = next() {s(ax)}", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{ax}, Value: &py.Call{Func: next}},
+		&py.While{
+			Test: &py.Compare{Left: ax, Ops: []py.CmpOp{py.NotEq}, Comparators: []py.Expr{pyNone}},
+			Body: append(s(ax), &py.Assign{Targets: []py.Expr{ax}, Value: &py.Call{Func: next}}),
+		},
+	}},
+	{"for cond() {s(0)}", []py.Stmt{
+		&py.While{Test: &py.Call{Func: cond}, Body: s(0)},
+	}},
+	// The function literal is defined on every iteration before the condition is tested
+	{"for func() bool { return b0 }() {s(0)}", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: append([]py.Stmt{
+				&py.FunctionDef{
+					Name: py.Identifier("func"),
+					Body: []py.Stmt{&py.Return{Value: b0}},
+				},
+				&py.If{
+					Test: &py.UnaryOpExpr{Op: py.Not, Operand: &py.Call{Func: &py.Name{Id: py.Identifier("func")}}},
+					Body: []py.Stmt{&py.Break{}},
+				},
+			}, s(0)...),
+		},
+	}},
 	{"for s(0); b0; s(1) {s(2)}",
 		append(s(0),
 			&py.While{
@@ -225,6 +554,12 @@ var stmtTests = []struct {
 			Value:   zero,
 		},
 	}},
+	{"var ax complex128; _ = ax", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax},
+			Value:   &py.Num{N: "0j"},
+		},
+	}},
 	{"var ax *int; _ = ax", []py.Stmt{
 		&py.Assign{
 			Targets: []py.Expr{ax},
@@ -255,6 +590,24 @@ var stmtTests = []struct {
 			Value:   pyNone,
 		},
 	}},
+	{"var ax [N][M]int; _ = ax", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax},
+			Value:   arrayZero(arrayZero(zero, 3), 2),
+		},
+	}},
+	{"var ax [0]int; _ = ax", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax},
+			Value:   &py.List{},
+		},
+	}},
+	{"var ax [N]T; _ = ax", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax},
+			Value:   arrayZero(&py.Call{Func: T}, 2),
+		},
+	}},
 	{"var ax, ay int; _, _ = ax, ay", []py.Stmt{
 		&py.Assign{
 			Targets: []py.Expr{ax, ay},
@@ -303,6 +656,39 @@ var stmtTests = []struct {
 			Value:   two,
 		},
 	}},
+	{"const Mask = 1<<8 - 1; _ = Mask", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{&py.Name{Id: py.Identifier("Mask")}},
+			Value:   &py.Num{N: "255"},
+		},
+	}},
+	{`const Greeting = "hello, " + "wor" + "ld"; _ = Greeting`, []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{&py.Name{Id: py.Identifier("Greeting")}},
+			Value:   &py.Str{S: `"hello, world"`},
+		},
+	}},
+	// iota is the same for every name in a spec
+	{"const (ax, ay = iota, iota * 2; az, aw)", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax, ay},
+			Value:   &py.Tuple{Elts: []py.Expr{zero, zero}},
+		},
+		&py.Assign{
+			Targets: []py.Expr{az, aw},
+			Value:   &py.Tuple{Elts: []py.Expr{one, two}},
+		},
+	}},
+	{"const (ax, ay = iota, iota + 10; az, aw)", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{ax, ay},
+			Value:   &py.Tuple{Elts: []py.Expr{zero, &py.Num{N: "10"}}},
+		},
+		&py.Assign{
+			Targets: []py.Expr{az, aw},
+			Value:   &py.Tuple{Elts: []py.Expr{one, &py.Num{N: "11"}}},
+		},
+	}},
 
 	// Type declarations
 	{"type T U", []py.Stmt{
@@ -312,7 +698,162 @@ var stmtTests = []struct {
 		},
 	}},
 	//{"type T interface{}", []py.Stmt{}},
-	//{"type T string", []py.Stmt{}},
+	{"type T string", []py.Stmt{
+		&py.ClassDef{
+			Name: T.Id,
+			Body: []py.Stmt{&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{
+					Args:     []py.Arg{{Arg: pySelf}, {Arg: py.Identifier("value")}},
+					Defaults: []py.Expr{&py.Str{S: `""`}},
+				},
+				Body: []py.Stmt{
+					&py.Assign{
+						Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: py.Identifier("value")}},
+						Value:   &py.Name{Id: py.Identifier("value")},
+					},
+				},
+			}},
+		},
+	}},
+	// Typed constants of wrapper types are wrapped
+	{`const ax ID = "a"; _ = ax`, []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: wrapID(`"a"`)}}},
+	{`takeID("a")`, []py.Stmt{&py.ExprStmt{Value: &py.Call{Func: takeID, Args: []py.Expr{wrapID(`"a"`)}}}}},
+	{`takeID(Root)`, []py.Stmt{&py.ExprStmt{Value: &py.Call{Func: takeID, Args: []py.Expr{&py.Name{Id: py.Identifier("Root")}}}}}},
+	{`takeID(Root + "/a")`, []py.Stmt{&py.ExprStmt{Value: &py.Call{Func: takeID, Args: []py.Expr{wrapID(`"root/a"`)}}}}},
+	// Embedded fields are initialised in declaration order
+	// An embedded struct is a base class that initializes its fields
+	{"type T struct { x int; V; y int }", []py.Stmt{
+		&py.ClassDef{
+			Name:  T.Id,
+			Bases: []py.Expr{V},
+			Body: []py.Stmt{&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{
+					Args:     []py.Arg{{Arg: pySelf}, {Arg: x.Id}, {Arg: items.Id}, {Arg: y.Id}},
+					Defaults: []py.Expr{zero, pyNone, zero},
+				},
+				Body: []py.Stmt{
+					&py.ExprStmt{Value: &py.Call{
+						Func: &py.Attribute{Value: &py.Call{Func: pySuper}, Attr: py.Identifier("__init__")},
+						Args: []py.Expr{items},
+					}},
+					&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: x.Id}}, Value: x},
+					&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: y.Id}}, Value: y},
+				},
+			}},
+		},
+	}},
+	// Only the first base class is initialized by super()
+	{"type T struct { U; V }", []py.Stmt{
+		&py.ClassDef{
+			Name:  T.Id,
+			Bases: []py.Expr{U, V},
+			Body: []py.Stmt{&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{
+					Args:     []py.Arg{{Arg: pySelf}, {Arg: items.Id}},
+					Defaults: []py.Expr{pyNone},
+				},
+				Body: []py.Stmt{
+					&py.ExprStmt{Value: &py.Call{
+						Func: &py.Attribute{Value: &py.Call{Func: pySuper}, Attr: py.Identifier("__init__")},
+					}},
+					&py.ExprStmt{Value: &py.Call{
+						Func: &py.Attribute{Value: V, Attr: py.Identifier("__init__")},
+						Args: []py.Expr{&py.Name{Id: pySelf}, items},
+					}},
+				},
+			}},
+		},
+	}},
+	// An embedded pointer, or a struct whose fields the other fields
+	// shadow, stays a field
+	{"type T struct { *V; items int }", []py.Stmt{
+		&py.ClassDef{
+			Name: T.Id,
+			Body: []py.Stmt{&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{
+					Args:     []py.Arg{{Arg: pySelf}, {Arg: V.Id}, {Arg: items.Id}},
+					Defaults: []py.Expr{pyNone, zero},
+				},
+				Body: []py.Stmt{
+					&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: V.Id}}, Value: V},
+					&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: items.Id}}, Value: items},
+				},
+			}},
+		},
+	}},
+	// The fields of an inherited struct are keyword arguments of their own
+	{"ax := P{1, U{}, 2}; _ = ax", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{ax},
+		Value: &py.Call{Func: &py.Name{Id: py.Identifier("P")}, Keywords: []py.Keyword{
+			{Arg: identPtr("x"), Value: one},
+			{Arg: identPtr("y"), Value: two},
+		}},
+	}}},
+	// Named map types are classes derived from dict
+	{"type T map[int]string", []py.Stmt{
+		&py.ClassDef{Name: T.Id, Bases: []py.Expr{pyDict}, Body: []py.Stmt{&py.Pass{}}},
+	}},
+	{`ax := Counts{"a": 1}; _ = ax`, []py.Stmt{&py.Assign{
+		Targets: []py.Expr{ax},
+		Value: &py.Call{Func: counts, Args: []py.Expr{
+			&py.Dict{Keys: []py.Expr{&py.Str{S: `"a"`}}, Values: []py.Expr{one}},
+		}},
+	}}},
+	{"ax := Counts{}; _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: &py.Call{Func: counts}}}},
+	{"ax := make(Counts); _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: &py.Call{Func: counts}}}},
+	{"var ax Counts; _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: pyNone}}},
+	// Their methods are called on the class, as a nil map is None
+	{`x = Counts(nil).Get("a")`, []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x},
+		Value: &py.Call{
+			Func: &py.Attribute{Value: counts, Attr: py.Identifier("Get")},
+			Args: []py.Expr{pyNone, &py.Str{S: `"a"`}},
+		},
+	}}},
+	// Named function types are aliases of typing.Callable
+	{"type T func(int)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Name{Id: py.Identifier("T")}},
+		Value: &py.Subscript{Value: typingCallable, Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{
+			&py.List{Elts: []py.Expr{pyInt}},
+			pyNone,
+		}}}},
+	}}},
+	// Aliases are referred to by name, which may be declared later
+	{"type T func(T) int", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Name{Id: py.Identifier("T")}},
+		Value: &py.Subscript{Value: typingCallable, Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{
+			&py.List{Elts: []py.Expr{&py.Str{S: `"T"`}}},
+			pyInt,
+		}}}},
+	}}},
+	{"type T func(string, ...int) (*T, error)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{&py.Name{Id: py.Identifier("T")}},
+		Value: &py.Subscript{Value: typingCallable, Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{
+			&py.Ellipsis{},
+			&py.Subscript{Value: typingTuple, Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{
+				&py.Str{S: `"T"`},
+				pyException,
+			}}}},
+		}}}},
+	}}},
+	{"var ax F = f1; _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: f1}}},
+	{"var ax F; _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: pyNone}}},
+	{"ax := F(f1); _ = ax", []py.Stmt{&py.Assign{Targets: []py.Expr{ax}, Value: f1}}},
+	// Type aliases
+	{"type T = string", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: pyStr}}},
+	{"type T = int64", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: pyInt}}},
+	{"type T = U", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: U}}},
+	{"type T = any", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: typingAny}}},
+	{"type T = interface{}", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: typingAny}}},
+	{"type T = *U", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: U}}},
+	{"type T = func()", []py.Stmt{&py.Assign{Targets: []py.Expr{T}, Value: &py.Subscript{
+		Value: typingCallable,
+		Slice: &py.Index{Value: &py.Tuple{Elts: []py.Expr{&py.List{Elts: []py.Expr{}}, pyNone}}},
+	}}}},
 	//{"type T int", []py.Stmt{}},
 	//{"type T bool", []py.Stmt{}},
 	//{"type T []U", []py.Stmt{}},
@@ -323,6 +864,7 @@ var stmtTests = []struct {
 			Body: []py.Stmt{&py.Pass{}},
 		},
 	}},
+	// A struct field's zero value is created by each call of __init__
 	{"type T struct { x U }", []py.Stmt{
 		&py.ClassDef{
 			Name: T.Id,
@@ -333,11 +875,13 @@ var stmtTests = []struct {
 						py.Arg{Arg: pySelf},
 						py.Arg{Arg: x.Id},
 					},
-					Defaults: []py.Expr{
-						&py.Call{Func: U},
-					},
+					Defaults: []py.Expr{pyNone},
 				},
 				Body: []py.Stmt{
+					&py.If{
+						Test: &py.Compare{Left: x, Ops: []py.CmpOp{py.Is}, Comparators: []py.Expr{pyNone}},
+						Body: []py.Stmt{&py.Assign{Targets: []py.Expr{x}, Value: &py.Call{Func: U}}},
+					},
 					&py.Assign{
 						Targets: []py.Expr{
 							&py.Attribute{
@@ -351,6 +895,63 @@ var stmtTests = []struct {
 			}},
 		},
 	}},
+	// Interfaces are abstract base classes
+	{"type T interface { Size() int; Scale(_ float64, by ...int) }", []py.Stmt{
+		&py.ClassDef{
+			Name:  T.Id,
+			Bases: []py.Expr{abcABC},
+			Body: []py.Stmt{
+				&py.FunctionDef{
+					Name: py.Identifier("Scale"),
+					Args: py.Arguments{
+						Args:   []py.Arg{{Arg: pySelf}, {Arg: py.Identifier("arg")}},
+						Vararg: &py.Arg{Arg: py.Identifier("by")},
+					},
+					Body:          []py.Stmt{&py.Pass{}},
+					DecoratorList: []py.Expr{abcAbstractMethod},
+				},
+				&py.FunctionDef{
+					Name:          py.Identifier("Size"),
+					Args:          py.Arguments{Args: []py.Arg{{Arg: pySelf}}},
+					Body:          []py.Stmt{&py.Pass{}},
+					DecoratorList: []py.Expr{abcAbstractMethod},
+				},
+				makeSubclassHook([]py.Expr{&py.Str{S: `"Scale"`}, &py.Str{S: `"Size"`}}),
+			},
+		},
+	}},
+	{"type T interface {}", []py.Stmt{
+		&py.ClassDef{Name: T.Id, Bases: []py.Expr{abcABC}, Body: []py.Stmt{&py.Pass{}}},
+	}},
+	// Methods promoted from an embedded interface delegate to its value
+	{"type T struct { Sizer }", []py.Stmt{
+		&py.ClassDef{
+			Name: T.Id,
+			Body: []py.Stmt{
+				&py.FunctionDef{
+					Name: py.Identifier("__init__"),
+					Args: py.Arguments{
+						Args:     []py.Arg{{Arg: pySelf}, {Arg: sizer.Id}},
+						Defaults: []py.Expr{pyNone},
+					},
+					Body: []py.Stmt{
+						&py.Assign{Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: sizer.Id}}, Value: sizer},
+					},
+				},
+				&py.FunctionDef{
+					Name: py.Identifier("Size"),
+					Args: py.Arguments{Args: []py.Arg{{Arg: pySelf}}, Vararg: &py.Arg{Arg: py.Identifier("args")}},
+					Body: []py.Stmt{&py.Return{Value: &py.Call{
+						Func: &py.Attribute{
+							Value: &py.Attribute{Value: &py.Name{Id: pySelf}, Attr: sizer.Id},
+							Attr:  py.Identifier("Size"),
+						},
+						Args: []py.Expr{&py.Starred{Value: &py.Name{Id: py.Identifier("args")}}},
+					}}},
+				},
+			},
+		},
+	}},
 
 	// Switch statements
 	{"switch {}", nil},
@@ -407,6 +1008,102 @@ var stmtTests = []struct {
 			},
 		},
 	}},
+	// A case that falls through also runs the body of the next case
+	{"switch x { case y: s(0); fallthrough; case z: s(1); default: s(2) }", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{tag},
+			Value:   x,
+		},
+		&py.If{
+			Test: &py.Compare{Left: tag, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.Eq}},
+			Body: append(s(0), s(1)...),
+			Orelse: []py.Stmt{
+				&py.If{
+					Test:   &py.Compare{Left: tag, Comparators: []py.Expr{z}, Ops: []py.CmpOp{py.Eq}},
+					Body:   s(1),
+					Orelse: s(2),
+				},
+			},
+		},
+	}},
+	// Nested switches evaluate their tags to different variables
+	{"switch x { case y: switch y { case z: s(x) } }", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{tag},
+			Value:   x,
+		},
+		&py.If{
+			Test: &py.Compare{Left: tag, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.Eq}},
+			Body: []py.Stmt{
+				&py.Assign{
+					Targets: []py.Expr{&py.Name{Id: py.Identifier("tag1")}},
+					Value:   y,
+				},
+				&py.If{
+					Test: &py.Compare{Left: &py.Name{Id: py.Identifier("tag1")}, Comparators: []py.Expr{z}, Ops: []py.CmpOp{py.Eq}},
+					Body: s(x),
+				},
+			},
+		},
+	}},
+	// A break that ends a case is dropped
+	{"switch x { case y: s(0); break }", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{tag},
+			Value:   x,
+		},
+		&py.If{
+			Test: &py.Compare{Left: tag, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.Eq}},
+			Body: s(0),
+		},
+	}},
+	// Any other break leaves the switch rather than the loop around it
+	{"for { switch x { case y: if b0 { break }; s(0) } }", []py.Stmt{&py.While{
+		Test: pyTrue,
+		Body: []py.Stmt{
+			&py.Assign{
+				Targets: []py.Expr{tag},
+				Value:   x,
+			},
+			&py.While{
+				Test: pyTrue,
+				Body: []py.Stmt{
+					&py.If{
+						Test: &py.Compare{Left: tag, Comparators: []py.Expr{y}, Ops: []py.CmpOp{py.Eq}},
+						Body: []py.Stmt{&py.If{Test: b0, Body: []py.Stmt{&py.Break{}}}, s(0)[0]},
+					},
+					&py.Break{},
+				},
+			},
+		},
+	}}},
+
+	// Comma-ok type assertions compare the dynamic type, which is the class
+	// of T for *T
+	{"ax, ok := obj.(*T); _, _ = ax, ok", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{ax, okName},
+		Value: &py.IfExp{
+			Test: &py.Compare{
+				Left:        &py.Call{Func: pyType, Args: []py.Expr{obj}},
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{T},
+			},
+			Body:   &py.Tuple{Elts: []py.Expr{obj, pyTrue}},
+			Orelse: &py.Tuple{Elts: []py.Expr{pyNone, pyFalse}},
+		},
+	}}},
+	{"x, ok = obj.(int)", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x, okName},
+		Value: &py.IfExp{
+			Test: &py.Compare{
+				Left:        &py.Call{Func: pyType, Args: []py.Expr{obj}},
+				Ops:         []py.CmpOp{py.Eq},
+				Comparators: []py.Expr{pyInt},
+			},
+			Body:   &py.Tuple{Elts: []py.Expr{obj, pyTrue}},
+			Orelse: &py.Tuple{Elts: []py.Expr{zero, pyFalse}},
+		},
+	}}},
 
 	// Type switch
 	{"switch s(0); obj.(type) { default: s(1); case T: s(2); case U: s(3)}", []py.Stmt{
@@ -436,21 +1133,57 @@ var stmtTests = []struct {
 		&py.If{
 			Test: &py.Compare{Left: y, Comparators: []py.Expr{T}, Ops: []py.CmpOp{py.Eq}},
 			Body: append([]py.Stmt{
-				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y2")}}, Value: y}},
+				&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y2")}}, Value: obj}},
 				s(2, &py.Name{Id: py.Identifier("y2")})...),
 			Orelse: []py.Stmt{
 				&py.If{
 					Test: &py.Compare{Left: y, Comparators: []py.Expr{U}, Ops: []py.CmpOp{py.Eq}},
 					Body: append([]py.Stmt{
-						&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y3")}}, Value: y}},
+						&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y3")}}, Value: obj}},
 						s(3, &py.Name{Id: py.Identifier("y3")})...),
 					Orelse: append([]py.Stmt{
-						&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y1")}}, Value: y}},
+						&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("y1")}}, Value: obj}},
 						s(1, &py.Name{Id: py.Identifier("y1")})...),
 				},
 			},
 		},
 	}},
+	// Nested type switches
+	{"switch obj.(type) { case T: switch ax := obj.(type) { case T: s(ax); case U: s(1) }; case U: s(0) }", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{tag},
+			Value:   &py.Call{Func: pyType, Args: []py.Expr{obj}},
+		},
+		&py.If{
+			Test: &py.Compare{Left: tag, Comparators: []py.Expr{T}, Ops: []py.CmpOp{py.Eq}},
+			Body: []py.Stmt{
+				&py.Assign{
+					Targets: []py.Expr{ax},
+					Value:   &py.Call{Func: pyType, Args: []py.Expr{obj}},
+				},
+				&py.If{
+					Test: &py.Compare{Left: ax, Comparators: []py.Expr{T}, Ops: []py.CmpOp{py.Eq}},
+					Body: append([]py.Stmt{
+						&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("ax1")}}, Value: obj}},
+						s(&py.Name{Id: py.Identifier("ax1")})...),
+					Orelse: []py.Stmt{
+						&py.If{
+							Test: &py.Compare{Left: ax, Comparators: []py.Expr{U}, Ops: []py.CmpOp{py.Eq}},
+							Body: append([]py.Stmt{
+								&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("ax2")}}, Value: obj}},
+								s(1)...),
+						},
+					},
+				},
+			},
+			Orelse: []py.Stmt{
+				&py.If{
+					Test: &py.Compare{Left: tag, Comparators: []py.Expr{U}, Ops: []py.CmpOp{py.Eq}},
+					Body: s(0),
+				},
+			},
+		},
+	}},
 	{"switch obj.(type) { default: s(0)}", []py.Stmt{
 		&py.Assign{
 			Targets: []py.Expr{tag},
@@ -458,6 +1191,17 @@ var stmtTests = []struct {
 		},
 		s(0)[0],
 	}},
+	// A nil case matches the nil interface
+	{"switch obj.(type) { case nil: s(0)}", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{tag},
+			Value:   &py.Call{Func: pyType, Args: []py.Expr{obj}},
+		},
+		&py.If{
+			Test: &py.Compare{Left: obj, Ops: []py.CmpOp{py.Is}, Comparators: []py.Expr{pyNone}},
+			Body: s(0),
+		},
+	}},
 	{"switch obj.(type) {}", []py.Stmt{
 		&py.Assign{
 			Targets: []py.Expr{tag},
@@ -469,7 +1213,7 @@ var stmtTests = []struct {
 	{"delete(m, y)", []py.Stmt{
 		&py.Try{
 			Body: []py.Stmt{
-				&py.Delete{Targets: []py.Expr{&py.Subscript{Value: m, Slice: &py.Index{Value: y}}}},
+				&py.Delete{Targets: []py.Expr{&py.Subscript{Value: orEmptyMap(m), Slice: &py.Index{Value: y}}}},
 			},
 			Handlers: []py.ExceptHandler{
 				{Typ: &py.Name{Id: py.Identifier("KeyError")},
@@ -477,6 +1221,283 @@ var stmtTests = []struct {
 			},
 		},
 	}},
+
+	// Go statements start a thread that calls the function with the
+	// arguments evaluated now
+	{"go f2(x, y)", []py.Stmt{&py.ExprStmt{Value: &py.Call{
+		Func: goStart,
+		Args: []py.Expr{&py.Name{Id: py.Identifier("f2")}, x, y},
+	}}}},
+	{"go func(n int) { s(n, x) }(y)", []py.Stmt{
+		&py.FunctionDef{
+			Name: py.Identifier("func"),
+			Args: py.Arguments{Args: []py.Arg{{Arg: py.Identifier("n")}}},
+			Body: s(&py.Name{Id: py.Identifier("n")}, x),
+		},
+		&py.ExprStmt{Value: &py.Call{
+			Func: goStart,
+			Args: []py.Expr{&py.Name{Id: py.Identifier("func")}, y},
+		}},
+	}},
+
+	// Send
+	{"ch <- x + 1", []py.Stmt{&py.ExprStmt{Value: &py.Call{
+		Func: &py.Attribute{Value: ch, Attr: py.Identifier("send")},
+		Args: []py.Expr{&py.BinOp{Left: x, Op: py.Add, Right: one}},
+	}}}},
+
+	// Receive
+	{"x = <-ch", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x},
+		Value:   &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("recv")}},
+	}}},
+	{"x, ok = <-ch", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x, okName},
+		Value:   &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("recvOk")}},
+	}}},
+	{"<-ch", []py.Stmt{&py.ExprStmt{
+		Value: &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("recv")}},
+	}}},
+
+	// Close
+	{"close(ch)", []py.Stmt{&py.ExprStmt{
+		Value: &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("close")}},
+	}}},
+
+	// Select
+	{"select { case ch <- x: s(0); default: s(1) }", []py.Stmt{
+		&py.If{Test: trySend(ch, x), Body: s(0), Orelse: s(1)},
+	}},
+	{"select { case ch <- x: s(0); case ch <- y: s(1) }", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.If{
+					Test: trySend(ch, x),
+					Body: append(s(0), &py.Break{}),
+					Orelse: []py.Stmt{&py.If{
+						Test: trySend(ch, y),
+						Body: append(s(1), &py.Break{}),
+					}},
+				},
+				&py.ExprStmt{Value: &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Gosched")}}},
+			},
+		},
+	}},
+	// A receive case binds the value received if the channel was ready
+	{"select { case ax := <-ch: s(ax); default: s(1) }", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{recv}, Value: tryRecv(ch)},
+		&py.If{
+			Test:   recv,
+			Body:   append([]py.Stmt{&py.Assign{Targets: []py.Expr{&py.Tuple{Elts: []py.Expr{ax, &py.Name{Id: py.Identifier("_")}}}}, Value: recv}}, s(ax)...),
+			Orelse: s(1),
+		},
+	}},
+	// Each receive case tries its channel only if the cases before were not ready
+	{"select { case <-ch: s(0); case x, ok = <-ch: s(1) }", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.Assign{Targets: []py.Expr{recv}, Value: tryRecv(ch)},
+				&py.If{
+					Test: recv,
+					Body: append(s(0), &py.Break{}),
+					Orelse: []py.Stmt{
+						&py.Assign{Targets: []py.Expr{recv1}, Value: tryRecv(ch)},
+						&py.If{
+							Test: recv1,
+							Body: []py.Stmt{
+								&py.Assign{Targets: []py.Expr{&py.Tuple{Elts: []py.Expr{x, okName}}}, Value: recv1},
+								s(1)[0],
+								&py.Break{},
+							},
+						},
+					},
+				},
+				&py.ExprStmt{Value: &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Gosched")}}},
+			},
+		},
+	}},
+	// A labeled break leaves the select and the loop
+	{"L: for { select { case ch <- x: break L } }", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{breakL}, Value: pyFalse},
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.While{
+					Test: pyTrue,
+					Body: []py.Stmt{
+						&py.If{
+							Test: trySend(ch, x),
+							Body: []py.Stmt{&py.Assign{Targets: []py.Expr{breakL}, Value: pyTrue}, &py.Break{}},
+						},
+						&py.ExprStmt{Value: &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Gosched")}}},
+					},
+				},
+				&py.If{Test: breakL, Body: []py.Stmt{&py.Break{}}},
+			},
+		},
+	}},
+	// A labeled break from the loop it labels needs no flag
+	{"L: for { select { case ch <- x: s(0); default: break L } }", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.If{Test: trySend(ch, x), Body: s(0), Orelse: []py.Stmt{&py.Break{}}},
+			},
+		},
+	}},
+	// A labeled continue from a nested loop breaks it and continues the
+	// labeled loop, running its post statement
+	{"L: for ; x < 10; x++ { for range xs { if b0 { continue L }; s(0) } }", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{continueL}, Value: pyFalse},
+		&py.While{
+			Test: &py.Compare{Left: x, Ops: []py.CmpOp{py.Lt}, Comparators: []py.Expr{&py.Num{N: "10"}}},
+			Body: []py.Stmt{
+				&py.For{
+					Target: &py.Name{Id: py.Identifier("_")},
+					Iter:   xs,
+					Body: []py.Stmt{
+						&py.If{Test: b0, Body: []py.Stmt{&py.Assign{Targets: []py.Expr{continueL}, Value: pyTrue}, &py.Break{}}},
+						s(0)[0],
+					},
+				},
+				&py.If{Test: continueL, Body: []py.Stmt{
+					&py.Assign{Targets: []py.Expr{continueL}, Value: pyFalse},
+					&py.AugAssign{Target: x, Op: py.Add, Value: one},
+					&py.Continue{},
+				}},
+				&py.AugAssign{Target: x, Op: py.Add, Value: one},
+			},
+		},
+	}},
+	// A break inside a select in a loop leaves the select, not the loop
+	{"for { select { case ch <- x: break; default: s(0) } }", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.While{
+					Test: pyTrue,
+					Body: []py.Stmt{
+						&py.If{
+							Test:   trySend(ch, x),
+							Body:   []py.Stmt{&py.Break{}},
+							Orelse: []py.Stmt{s(0)[0], &py.Break{}},
+						},
+					},
+				},
+			},
+		},
+	}},
+	// A continue inside a select that compiles to a loop leaves the select
+	// and continues the enclosing loop
+	{"for { select { case ch <- x: continue; case ch <- y: s(0) } }", []py.Stmt{
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.Assign{Targets: []py.Expr{continued}, Value: pyFalse},
+				&py.While{
+					Test: pyTrue,
+					Body: []py.Stmt{
+						&py.If{
+							Test: trySend(ch, x),
+							Body: []py.Stmt{&py.Assign{Targets: []py.Expr{continued}, Value: pyTrue}, &py.Break{}},
+							Orelse: []py.Stmt{&py.If{
+								Test: trySend(ch, y),
+								Body: []py.Stmt{s(0)[0], &py.Break{}},
+							}},
+						},
+						&py.ExprStmt{Value: &py.Call{Func: &py.Attribute{Value: runtimeModule, Attr: py.Identifier("Gosched")}}},
+					},
+				},
+				&py.If{Test: continued, Body: []py.Stmt{&py.Continue{}}},
+			},
+		},
+	}},
+	// The value is evaluated once and break leaves the select
+	{"select { case ch <- f0(): break; default: }", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{&py.Name{Id: py.Identifier("value")}}, Value: &py.Call{Func: &py.Name{Id: py.Identifier("f0")}}},
+		&py.While{
+			Test: pyTrue,
+			Body: []py.Stmt{
+				&py.If{
+					Test:   trySend(ch, &py.Name{Id: py.Identifier("value")}),
+					Body:   []py.Stmt{&py.Break{}},
+					Orelse: []py.Stmt{&py.Break{}},
+				},
+			},
+		},
+	}},
+}
+
+var (
+	breakL    = &py.Name{Id: py.Identifier("breakL")}
+	continued = &py.Name{Id: py.Identifier("continued")}
+	V         = &py.Name{Id: py.Identifier("V")}
+	items     = &py.Name{Id: py.Identifier("items")}
+	recv      = &py.Name{Id: py.Identifier("recv")}
+	recv1     = &py.Name{Id: py.Identifier("recv1")}
+	continueL = &py.Name{Id: py.Identifier("continueL")}
+	takeID    = &py.Name{Id: py.Identifier("takeID")}
+	next      = &py.Name{Id: py.Identifier("next")}
+	cond      = &py.Name{Id: py.Identifier("cond")}
+)
+
+func wrapID(lit string) py.Expr {
+	return &py.Call{Func: &py.Name{Id: py.Identifier("ID")}, Args: []py.Expr{&py.Str{S: lit}}}
+}
+
+var (
+	ch = &py.Name{Id: py.Identifier("ch")}
+	ms = &py.Name{Id: py.Identifier("ms")}
+)
+
+// arrayZero returns the zero value of an array of length n
+func arrayZero(elt py.Expr, n int) py.Expr {
+	return &py.ListComp{
+		Elt: elt,
+		Generators: []py.Comprehension{{
+			Target: &py.Name{Id: py.Identifier("_")},
+			Iter:   &py.Call{Func: pyRange, Args: []py.Expr{&py.Num{N: strconv.Itoa(n)}}},
+		}},
+	}
+}
+
+func writableMap(m py.Expr) py.Expr {
+	return &py.Call{Func: goWritableMap, Args: []py.Expr{m}}
+}
+
+// recvLoop returns the statements that start each iteration of a range over ch.
+func recvLoop(ch py.Expr) []py.Stmt {
+	return recvValueLoop(ch, &py.Name{Id: py.Identifier("_")})
+}
+
+// recvValueLoop returns the statements that start each iteration of a range
+// over ch that assigns each value received to value.
+func recvValueLoop(ch, value py.Expr) []py.Stmt {
+	ok := &py.Name{Id: py.Identifier("ok")}
+	return []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{&py.Tuple{Elts: []py.Expr{value, ok}}},
+			Value:   &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("recvOk")}},
+		},
+		&py.If{Test: &py.UnaryOpExpr{Op: py.Not, Operand: ok}, Body: []py.Stmt{&py.Break{}}},
+	}
+}
+
+// trySend and tryRecv are select cases on ch, which is never ready if it is
+// nil
+func trySend(ch, value py.Expr) py.Expr {
+	return notNoneAnd(ch, &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("trySend")}, Args: []py.Expr{value}})
+}
+
+func tryRecv(ch py.Expr) py.Expr {
+	return notNoneAnd(ch, &py.Call{Func: &py.Attribute{Value: ch, Attr: py.Identifier("tryRecv")}})
+}
+
+func notNoneAnd(ch, try py.Expr) py.Expr {
+	isNotNone := &py.Compare{Left: ch, Ops: []py.CmpOp{py.IsNot}, Comparators: []py.Expr{pyNone}}
+	return &py.BoolOpExpr{Op: py.And, Values: []py.Expr{isNotNone, try}}
 }
 
 func pythonCode(stmts []py.Stmt) string {
@@ -486,8 +1507,13 @@ func pythonCode(stmts []py.Stmt) string {
 	return buf.String()
 }
 
-func TestStmt(t *testing.T) {
-	for _, test := range stmtTests {
+type stmtTest struct {
+	golang string
+	python []py.Stmt
+}
+
+func runStmtTests(t *testing.T, tests []stmtTest, options Options) {
+	for _, test := range tests {
 		t.Run(test.golang, func(t *testing.T) {
 			pkg, file, errs := buildFile(fmt.Sprintf(stmtPkgTemplate, test.golang))
 			if errs != nil {
@@ -499,6 +1525,7 @@ func TestStmt(t *testing.T) {
 			}
 
 			c := NewCompiler(&pkg.Info, nil)
+			c.Options = options
 			goStmt := file.Scope.Lookup("main").Decl.(*ast.FuncDecl).Body.List[0]
 			pyStmts := c.compileStmt(goStmt)
 			if !reflect.DeepEqual(pyStmts, test.python) {
@@ -507,3 +1534,104 @@ func TestStmt(t *testing.T) {
 		})
 	}
 }
+
+func TestStmt(t *testing.T) {
+	runStmtTests(t, stmtTests, Options{})
+}
+
+var conditionalExpressionTests = []stmtTest{
+	{"if b0 { x = 1 } else { x = 2 }", []py.Stmt{
+		&py.Assign{Targets: []py.Expr{x}, Value: &py.IfExp{Test: b0, Body: one, Orelse: two}},
+	}},
+	// Different targets
+	{"if b0 { x = 1 } else { y = 2 }", []py.Stmt{
+		&py.If{
+			Test:   b0,
+			Body:   []py.Stmt{&py.Assign{Targets: []py.Expr{x}, Value: one}},
+			Orelse: []py.Stmt{&py.Assign{Targets: []py.Expr{y}, Value: two}},
+		},
+	}},
+	// More than one statement
+	{"if b0 { x = 1 } else { x = 2; y = 1 }", []py.Stmt{
+		&py.If{
+			Test: b0,
+			Body: []py.Stmt{&py.Assign{Targets: []py.Expr{x}, Value: one}},
+			Orelse: []py.Stmt{
+				&py.Assign{Targets: []py.Expr{x}, Value: two},
+				&py.Assign{Targets: []py.Expr{y}, Value: one},
+			},
+		},
+	}},
+}
+
+func TestConditionalExpressions(t *testing.T) {
+	runStmtTests(t, conditionalExpressionTests, Options{ConditionalExpressions: true})
+}
+
+var underscoreUnexportedTests = []stmtTest{
+	{"type T struct { x, Y int }", []py.Stmt{
+		&py.ClassDef{
+			Name: T.Id,
+			Body: []py.Stmt{&py.FunctionDef{
+				Name: py.Identifier("__init__"),
+				Args: py.Arguments{
+					Args:     []py.Arg{{Arg: pySelf}, {Arg: py.Identifier("_x")}, {Arg: py.Identifier("Y")}},
+					Defaults: []py.Expr{zero, zero},
+				},
+				Body: []py.Stmt{
+					&py.Assign{
+						Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: py.Identifier("_x")}},
+						Value:   &py.Name{Id: py.Identifier("_x")},
+					},
+					&py.Assign{
+						Targets: []py.Expr{&py.Attribute{Value: &py.Name{Id: pySelf}, Attr: py.Identifier("Y")}},
+						Value:   &py.Name{Id: py.Identifier("Y")},
+					},
+				},
+			}},
+		},
+	}},
+	{"t0 = T{x: 1}", []py.Stmt{
+		&py.Assign{
+			Targets: []py.Expr{t0},
+			Value:   &py.Call{Func: T, Keywords: []py.Keyword{{Arg: identPtr("_x"), Value: one}}},
+		},
+	}},
+}
+
+func identPtr(s string) *py.Identifier {
+	id := py.Identifier(s)
+	return &id
+}
+
+var (
+	set0 = &py.Name{Id: py.Identifier("set0")}
+	v0   = &py.Name{Id: py.Identifier("v0")}
+)
+
+var setTests = []stmtTest{
+	{"set0 = make(map[int]struct{})", []py.Stmt{&py.Assign{Targets: []py.Expr{set0}, Value: &py.Set{}}}},
+	{"set0 = map[int]struct{}{x: {}, y: {}}", []py.Stmt{&py.Assign{Targets: []py.Expr{set0}, Value: &py.Set{Elts: []py.Expr{x, y}}}}},
+	{"set0[x] = struct{}{}", []py.Stmt{&py.ExprStmt{Value: &py.Call{
+		Func: &py.Attribute{Value: set0, Attr: py.Identifier("add")},
+		Args: []py.Expr{x},
+	}}}},
+	{"_, b0 = set0[x]", []py.Stmt{&py.Assign{
+		Targets: []py.Expr{b0},
+		Value:   &py.Compare{Left: x, Ops: []py.CmpOp{py.In}, Comparators: []py.Expr{orEmptyMap(set0)}},
+	}}},
+	{"delete(set0, x)", []py.Stmt{&py.ExprStmt{Value: &py.Call{
+		Func: &py.Attribute{Value: orEmptySet(set0), Attr: py.Identifier("discard")},
+		Args: []py.Expr{x},
+	}}}},
+	{"for x := range set0 {s(x)}", []py.Stmt{&py.For{Target: x, Iter: listOf(orEmptyMap(set0)), Body: s(x)}}},
+	{"for range set0 {s(x)}", []py.Stmt{&py.For{Target: &py.Name{Id: py.Identifier("_")}, Iter: listOf(orEmptyMap(set0)), Body: s(x)}}},
+}
+
+func TestSets(t *testing.T) {
+	runStmtTests(t, setTests, Options{Sets: true})
+}
+
+func TestUnderscoreUnexported(t *testing.T) {
+	runStmtTests(t, underscoreUnexportedTests, Options{UnderscoreUnexported: true})
+}
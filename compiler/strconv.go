@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+	"go/constant"
+	"strconv"
+)
+
+var pyFormat = &py.Name{Id: py.Identifier("format")}
+
+// Python format specs of the integer bases supported by FormatInt
+var baseFormatSpecs = map[int64]string{
+	2:  "b",
+	8:  "o",
+	16: "x",
+}
+
+// Runtime functions that parse integers as strconv does
+var parseFuncs = map[string]py.Expr{
+	"ParseInt":  goParseInt,
+	"ParseUint": goParseUint,
+}
+
+// compileStrconvCall compiles calls to the integer conversions in package strconv.
+func (c *exprCompiler) compileStrconvCall(name string, expr *ast.CallExpr) py.Expr {
+	switch name {
+	case "FormatInt", "FormatUint":
+		n := c.compileExpr(expr.Args[0])
+		base := c.Types[expr.Args[1]].Value
+		if base == nil {
+			panic(c.err(expr, "%s with a non-constant base is not supported", name))
+		}
+		b, _ := constant.Int64Val(base)
+		if b == 10 {
+			return &py.Call{Func: pyStr, Args: []py.Expr{n}}
+		}
+		spec, ok := baseFormatSpecs[b]
+		if !ok {
+			panic(c.err(expr, "%s with base %d is not supported", name, b))
+		}
+		return &py.Call{Func: pyFormat, Args: []py.Expr{n, &py.Str{S: strconv.Quote(spec)}}}
+	case "ParseInt", "ParseUint":
+		// Python's int raises ValueError and has no bit size, so the runtime
+		// checks the syntax and range and returns the error
+		return &py.Call{Func: parseFuncs[name], Args: c.compileExprs(expr.Args)}
+	}
+	return nil
+}
+
+// strconvUsesRuntime reports whether the strconv call refers to the runtime
+// module.
+func (c *Compiler) strconvUsesRuntime(call *ast.CallExpr) bool {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if pkg := c.importedPackage(sel.X); pkg == nil || pkg.Path() != "strconv" {
+		return false
+	}
+	return parseFuncs[sel.Sel.Name] != nil
+}
@@ -0,0 +1,37 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"testing"
+)
+
+var (
+	i64     = &py.Name{Id: py.Identifier("i64")}
+	u64     = &py.Name{Id: py.Identifier("u64")}
+	errName = &py.Name{Id: py.Identifier("err")}
+)
+
+func formatCall(n py.Expr, spec string) py.Expr {
+	return &py.Call{Func: pyFormat, Args: []py.Expr{n, &py.Str{S: spec}}}
+}
+
+func parseIntCall(target py.Expr, parse py.Expr, s py.Expr, base string, bitSize string) []py.Stmt {
+	return []py.Stmt{&py.Assign{
+		Targets: []py.Expr{target, errName},
+		Value:   &py.Call{Func: parse, Args: []py.Expr{s, &py.Num{N: base}, &py.Num{N: bitSize}}},
+	}}
+}
+
+var strconvTests = []stmtTest{
+	{`str = strconv.FormatInt(i64, 16)`, assignStr(formatCall(i64, `"x"`))},
+	{`str = strconv.FormatInt(i64, 8)`, assignStr(formatCall(i64, `"o"`))},
+	{`str = strconv.FormatInt(i64, 2)`, assignStr(formatCall(i64, `"b"`))},
+	{`str = strconv.FormatInt(i64, 10)`, assignStr(&py.Call{Func: pyStr, Args: []py.Expr{i64}})},
+	{`str = strconv.FormatUint(u64, 16)`, assignStr(formatCall(u64, `"x"`))},
+	{`i64, err = strconv.ParseInt(str, 2, 64)`, parseIntCall(i64, goParseInt, str, "2", "64")},
+	{`u64, err = strconv.ParseUint(str, 16, 32)`, parseIntCall(u64, goParseUint, str, "16", "32")},
+}
+
+func TestStrconv(t *testing.T) {
+	runStmtTests(t, strconvTests, Options{})
+}
@@ -0,0 +1,46 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"go/ast"
+)
+
+// Functions of package strings that are a method of their first argument
+// in Python
+var stringsMethods = map[string]py.Identifier{
+	"ToUpper":   py.Identifier("upper"),
+	"ToLower":   py.Identifier("lower"),
+	"TrimSpace": py.Identifier("strip"),
+	"HasPrefix": py.Identifier("startswith"),
+	"HasSuffix": py.Identifier("endswith"),
+	"Index":     py.Identifier("find"),
+	"Count":     py.Identifier("count"),
+}
+
+// compileStringsCall compiles calls to the functions in package strings
+// that have a Python str counterpart.
+func (c *exprCompiler) compileStringsCall(name string, expr *ast.CallExpr) py.Expr {
+	if method, ok := stringsMethods[name]; ok {
+		return &py.Call{
+			Func: &py.Attribute{Value: c.compileExpr(expr.Args[0]), Attr: method},
+			Args: c.compileExprs(expr.Args[1:]),
+		}
+	}
+	switch name {
+	case "Contains":
+		return &py.Compare{
+			Left:        c.compileExpr(expr.Args[1]),
+			Ops:         []py.CmpOp{py.In},
+			Comparators: []py.Expr{c.compileExpr(expr.Args[0])},
+		}
+	case "Repeat":
+		return &py.BinOp{Left: c.compileExpr(expr.Args[0]), Op: py.Mult, Right: c.compileExpr(expr.Args[1])}
+	case "Join":
+		// The separator joins the elements, which may be a nil slice
+		return &py.Call{
+			Func: &py.Attribute{Value: c.compileExpr(expr.Args[1]), Attr: py.Identifier("join")},
+			Args: []py.Expr{c.compileSlice(expr.Args[0])},
+		}
+	}
+	return nil
+}
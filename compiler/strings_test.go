@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	py "github.com/mbergin/gotopython/pythonast"
+	"testing"
+)
+
+func strMethod(s py.Expr, method string, args ...py.Expr) py.Expr {
+	return &py.Call{Func: &py.Attribute{Value: s, Attr: py.Identifier(method)}, Args: args}
+}
+
+func assignOk(value py.Expr) []py.Stmt {
+	return []py.Stmt{&py.Assign{Targets: []py.Expr{okName}, Value: value}}
+}
+
+var strSlice = &py.Subscript{Value: str, Slice: &py.RangeSlice{Lower: two, Upper: &py.Num{N: "5"}}}
+
+var stringsTests = []stmtTest{
+	{`str = strings.ToUpper(str)`, assignStr(strMethod(str, "upper"))},
+	{`str = strings.ToLower(str)`, assignStr(strMethod(str, "lower"))},
+	{`str = strings.TrimSpace(str)`, assignStr(strMethod(str, "strip"))},
+	// Slicing a string gives a string
+	{`str = strings.ToUpper(str[2:5])`, assignStr(strMethod(strSlice, "upper"))},
+	{`ok = strings.HasPrefix(str[2:5], "ab")`, assignOk(strMethod(strSlice, "startswith", &py.Str{S: `"ab"`}))},
+	{`ok = strings.HasSuffix(str, "ab")`, assignOk(strMethod(str, "endswith", &py.Str{S: `"ab"`}))},
+	{`x = strings.Index(str, "ab")`, []py.Stmt{&py.Assign{
+		Targets: []py.Expr{x},
+		Value:   strMethod(str, "find", &py.Str{S: `"ab"`}),
+	}}},
+	{`ok = strings.Contains(str, "ab")`, assignOk(&py.Compare{
+		Left:        &py.Str{S: `"ab"`},
+		Ops:         []py.CmpOp{py.In},
+		Comparators: []py.Expr{str},
+	})},
+	{`str = strings.Repeat(str, x)`, assignStr(&py.BinOp{Left: str, Op: py.Mult, Right: x})},
+}
+
+func TestStrings(t *testing.T) {
+	runStmtTests(t, stringsTests, Options{})
+}
@@ -10,13 +10,24 @@ import (
 	"go/build"
 	"go/parser"
 	"golang.org/x/tools/go/loader"
+	"io/ioutil"
 	"os"
+	"strings"
 )
 
 var (
 	dumpGoAST     = flag.Bool("g", false, "Dump the Go syntax tree to stdout")
 	dumpPythonAST = flag.Bool("p", false, "Dump the Python syntax tree to stdout")
 	output        = flag.String("o", "", "Write the Python module to this file")
+	underscore    = flag.Bool("underscore", false, "Prefix unexported struct fields and methods with an underscore")
+	sets          = flag.Bool("sets", false, "Compile maps with struct{} values to sets")
+	ternary       = flag.Bool("ternary", false, "Compile if/else assignments to the same variable as conditional expressions")
+	enums         = flag.Bool("enums", false, "Compile named integer types with iota constants to IntEnum classes")
+	analyze       = flag.Bool("analyze", false, "Report the constructs that cannot be compiled instead of compiling")
+	goos          = flag.String("goos", "", "Replace runtime.GOOS with this target operating system")
+	goarch        = flag.String("goarch", "", "Replace runtime.GOARCH with this target architecture")
+	runtime       = flag.String("runtime", "", "Write the Python runtime module that compiled modules import to this file")
+	modules       = flag.String("modules", "", "Import the Go packages as these Python modules, as a comma-separated list of path=module")
 )
 
 const (
@@ -25,8 +36,26 @@ const (
 	errOutput
 	errNoDir
 	errBuild
+	errUnsupported
 )
 
+// parseModules parses the -modules flag into a map from Go import paths to
+// Python module names.
+func parseModules(s string) (map[string]string, error) {
+	modules := map[string]string{}
+	if s == "" {
+		return modules, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("bad -modules entry %q: want path=module", pair)
+		}
+		modules[pair[:i]] = pair[i+1:]
+	}
+	return modules, nil
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: gotopython [flags] package\n")
 	flag.PrintDefaults()
@@ -36,11 +65,27 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if *runtime != "" {
+		if err := ioutil.WriteFile(*runtime, []byte(compiler.Runtime), 0666); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(errOutput)
+		}
+		if flag.NArg() == 0 {
+			return
+		}
+	}
+
 	if flag.NArg() == 0 {
 		flag.Usage()
 		os.Exit(errNoDir)
 	}
 
+	pyModules, err := parseModules(*modules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(errArgs)
+	}
+
 	var loaderConfig loader.Config
 	buildContext := build.Default
 	//buildContext.GOARCH = "python"
@@ -49,7 +94,7 @@ func main() {
 	loaderConfig.ParserMode |= parser.ParseComments
 
 	const xtest = false
-	_, err := loaderConfig.FromArgs(flag.Args(), xtest)
+	_, err = loaderConfig.FromArgs(flag.Args(), xtest)
 	// TODO ignoring args after "--"
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -71,6 +116,25 @@ func main() {
 		}
 
 		c := compiler.NewCompiler(&pkg.Info, program.Fset)
+		c.Options.ConditionalExpressions = *ternary
+		c.Options.UnderscoreUnexported = *underscore
+		c.Options.Sets = *sets
+		c.Options.Enums = *enums
+		c.Options.GOOS = *goos
+		c.Options.GOARCH = *goarch
+		c.Options.Modules = pyModules
+
+		if *analyze {
+			unsupported := c.Analyze(pkg.Files)
+			for _, u := range unsupported {
+				fmt.Println(u)
+			}
+			if len(unsupported) > 0 {
+				os.Exit(errUnsupported)
+			}
+			continue
+		}
+
 		module := c.CompileFiles(pkg.Files)
 
 		if *dumpPythonAST {
@@ -226,7 +226,7 @@ func (FormattedValue) Precedence() int { return 0 }
 func (JoinedStr) Precedence() int      { return 100 }
 func (Bytes) Precedence() int          { return 100 }
 func (NameConstant) Precedence() int   { return 100 }
-func (Ellipsis) Precedence() int       { return 0 }
+func (Ellipsis) Precedence() int       { return 100 }
 func (ConstantExpr) Precedence() int   { return 100 }
 
 func (Starred) Precedence() int { return 100 }
@@ -3,6 +3,7 @@ package pythonast
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
 type Writer struct {
@@ -58,8 +59,20 @@ func (w *Writer) writeStmt(stmt Stmt) {
 		w.write("continue")
 	case *Delete:
 		w.del(s)
+	case *Global:
+		w.names("global ", s.Names)
+	case *Nonlocal:
+		w.names("nonlocal ", s.Names)
+	case *Import:
+		w.importStmt(s)
+	case *ImportFrom:
+		w.importFrom(s)
 	case *Try:
 		w.try(s)
+	case *Raise:
+		w.raise(s)
+	case *With:
+		w.with(s)
 	case *Comment:
 		w.comment(s)
 	case *DocString:
@@ -103,6 +116,46 @@ func (w *Writer) del(s *Delete) {
 	}
 }
 
+func (w *Writer) names(keyword string, names []Identifier) {
+	w.write(keyword)
+	for i, name := range names {
+		if i > 0 {
+			w.comma()
+		}
+		w.write(string(name))
+	}
+}
+
+func (w *Writer) importStmt(s *Import) {
+	w.write("import ")
+	w.aliases(s.Names)
+}
+
+func (w *Writer) importFrom(s *ImportFrom) {
+	w.write("from ")
+	if s.Level != nil {
+		w.write(strings.Repeat(".", *s.Level))
+	}
+	if s.Module != nil {
+		w.write(string(*s.Module))
+	}
+	w.write(" import ")
+	w.aliases(s.Names)
+}
+
+func (w *Writer) aliases(names []Alias) {
+	for i, alias := range names {
+		if i > 0 {
+			w.comma()
+		}
+		w.write(string(alias.Name))
+		if alias.Asname != nil {
+			w.write(" as ")
+			w.write(string(*alias.Asname))
+		}
+	}
+}
+
 func (w *Writer) assign(s *Assign) {
 	for i, target := range s.Targets {
 		if i > 0 {
@@ -132,7 +185,7 @@ func (w *Writer) ifStmt(s *If) {
 	w.dedent()
 	if s.Orelse != nil {
 		w.newline()
-		if elif, ok := s.Orelse[0].(*If); ok {
+		if elif, ok := s.Orelse[0].(*If); ok && len(s.Orelse) == 1 {
 			w.write("el")
 			w.writeStmt(elif)
 		} else {
@@ -197,6 +250,36 @@ func (w *Writer) try(s *Try) {
 	}
 }
 
+func (w *Writer) raise(s *Raise) {
+	w.write("raise")
+	if s.Exc != nil {
+		w.write(" ")
+		w.WriteExpr(s.Exc)
+	}
+	if s.Cause != nil {
+		w.write(" from ")
+		w.WriteExpr(s.Cause)
+	}
+}
+
+func (w *Writer) with(s *With) {
+	w.write("with ")
+	for i, item := range s.Items {
+		if i > 0 {
+			w.comma()
+		}
+		w.WriteExpr(item.ContextExpr)
+		if item.OptionalVars != nil {
+			w.write(" as ")
+			w.WriteExpr(item.OptionalVars)
+		}
+	}
+	w.write(":")
+	w.indent()
+	w.writeStmts(s.Body)
+	w.dedent()
+}
+
 func (w *Writer) augAssign(s *AugAssign) {
 	w.WriteExpr(s.Target)
 	switch s.Op {
@@ -246,8 +329,13 @@ func (w *Writer) call(e *Call) {
 		if i != 0 {
 			w.comma()
 		}
-		w.identifier(*kw.Arg)
-		w.write("=")
+		if kw.Arg == nil {
+			// **kwargs
+			w.write("**")
+		} else {
+			w.identifier(*kw.Arg)
+			w.write("=")
+		}
 		w.writeExprPrec(kw.Value, prec)
 		i++
 	}
@@ -331,10 +419,14 @@ func (w *Writer) writeExprPrec(expr Expr, parentPrec int) {
 		w.identifier(e.Attr)
 	case *NameConstant:
 		w.nameConstant(e)
+	case *Ellipsis:
+		w.write("...")
 	case *List:
 		w.list(e)
 	case *Dict:
 		w.dict(e)
+	case *Set:
+		w.set(e)
 	case *Subscript:
 		w.writeExprPrec(e.Value, prec)
 		w.write("[")
@@ -350,6 +442,8 @@ func (w *Writer) writeExprPrec(expr Expr, parentPrec int) {
 		w.starred(e)
 	case *Lambda:
 		w.lambda(e)
+	case *IfExp:
+		w.ifExp(e)
 	default:
 		panic(fmt.Sprintf("unknown Expr: %T", expr))
 	}
@@ -365,6 +459,15 @@ func (w *Writer) lambda(e *Lambda) {
 	w.writeExprPrec(e.Body, e.Precedence())
 }
 
+func (w *Writer) ifExp(e *IfExp) {
+	prec := e.Precedence()
+	w.writeExprPrec(e.Body, prec+1)
+	w.write(" if ")
+	w.writeExprPrec(e.Test, prec+1)
+	w.write(" else ")
+	w.writeExprPrec(e.Orelse, prec)
+}
+
 func (w *Writer) starred(e *Starred) {
 	w.write("*")
 	w.writeExprPrec(e.Value, e.Precedence())
@@ -377,24 +480,27 @@ func (w *Writer) listComp(e *ListComp) {
 		w.write(" for ")
 		w.WriteExpr(g.Target)
 		w.write(" in ")
-		w.WriteExpr(g.Iter)
+		w.writeExprPrec(g.Iter, IfExp{}.Precedence()+1)
 		for _, ifExpr := range g.Ifs {
 			w.write(" if ")
-			w.WriteExpr(ifExpr)
+			w.writeExprPrec(ifExpr, IfExp{}.Precedence()+1)
 		}
 	}
 	w.write("]")
 }
 
 func (w *Writer) boolOpExpr(e *BoolOpExpr) {
-	w.writeExprPrec(e.Values[0], e.Precedence())
-	switch e.Op {
-	case Or:
-		w.write(" or ")
-	case And:
-		w.write(" and ")
+	for i, value := range e.Values {
+		if i > 0 {
+			switch e.Op {
+			case Or:
+				w.write(" or ")
+			case And:
+				w.write(" and ")
+			}
+		}
+		w.writeExprPrec(value, e.Precedence())
 	}
-	w.writeExprPrec(e.Values[1], e.Precedence())
 }
 
 func (w *Writer) unaryOpExpr(e *UnaryOpExpr) {
@@ -452,6 +558,22 @@ func (w *Writer) dict(d *Dict) {
 	w.write("}")
 }
 
+func (w *Writer) set(s *Set) {
+	if len(s.Elts) == 0 {
+		// {} is an empty dict
+		w.write("set()")
+		return
+	}
+	w.write("{")
+	for i, elt := range s.Elts {
+		if i > 0 {
+			w.comma()
+		}
+		w.writeExprPrec(elt, s.Precedence())
+	}
+	w.write("}")
+}
+
 func (w *Writer) nameConstant(nc *NameConstant) {
 	switch nc.Value {
 	case None:
@@ -533,10 +655,18 @@ func (w *Writer) args(args Arguments) {
 			w.WriteExpr(args.Defaults[i-defaultOffset])
 		}
 	}
+	if args.Vararg != nil {
+		if len(args.Args) > 0 {
+			w.comma()
+		}
+		w.write("*")
+		w.identifier(args.Vararg.Arg)
+	}
 }
 
 func (w *Writer) functionDef(s *FunctionDef) {
 	w.newline()
+	w.decorators(s.DecoratorList)
 	w.write("def ")
 	w.identifier(s.Name)
 	w.beginParen()
@@ -555,6 +685,7 @@ func (w *Writer) functionDef(s *FunctionDef) {
 
 func (w *Writer) classDef(s *ClassDef) {
 	w.newline()
+	w.decorators(s.DecoratorList)
 	w.write("class ")
 	w.identifier(s.Name)
 	if len(s.Bases) > 0 {
@@ -578,6 +709,15 @@ func (w *Writer) classDef(s *ClassDef) {
 	w.dedent()
 }
 
+// decorators writes each decorator on its own line before a definition.
+func (w *Writer) decorators(decorators []Expr) {
+	for _, decorator := range decorators {
+		w.write("@")
+		w.WriteExpr(decorator)
+		w.newline()
+	}
+}
+
 func (w *Writer) identifier(i Identifier) {
 	w.write(string(i))
 }
@@ -48,12 +48,17 @@ func star(e Expr) Expr {
 	return &Starred{Value: e}
 }
 
+func ifExp(test, body, orelse Expr) Expr {
+	return &IfExp{Test: test, Body: body, Orelse: orelse}
+}
+
 func TestExpr(t *testing.T) {
 	tests := []struct {
 		expr Expr
 		want string
 	}{
 		{a, "a"},
+		{&Subscript{Value: a, Slice: &Index{Value: tup(&Ellipsis{}, b)}}, "a[..., b]"},
 		{bin(a, Add, b), "a + b"},
 		{bin(bin(a, Sub, b), Sub, c), "a - b - c"},
 		{bin(bin(bin(a, Sub, b), Sub, c), Sub, d), "a - b - c - d"},
@@ -75,7 +80,19 @@ func TestExpr(t *testing.T) {
 		{tup(a, eq(b, c), d), "a, b == c, d"},
 		{tup(lambda(args(a), b), c), "lambda a: b, c"},
 		{lambda(args(a), tup(b, c)), "lambda a: (b, c)"},
+		{lambda(Arguments{Args: []Arg{{Arg: a.Id}}, Vararg: &Arg{Arg: b.Id}}, c), "lambda a, *b: c"},
+		{lambda(Arguments{Vararg: &Arg{Arg: b.Id}}, c), "lambda *b: c"},
 		{call(a, star(b)), "a(*b)"},
+		{&Call{Func: a, Keywords: []Keyword{{Value: call(b, c)}, {Arg: &d.Id, Value: c}}}, "a(**b(c), d=c)"},
+		{ifExp(a, b, c), "b if a else c"},
+		{ifExp(a, tup(b, c), tup(d, a)), "(b, c) if a else (d, a)"},
+		{ifExp(a, ifExp(b, c, d), ifExp(c, d, a)), "(c if b else d) if a else d if c else a"},
+		{&ListComp{Elt: a, Generators: []Comprehension{{Target: a, Iter: tup(b, c)}}}, "[a for a in (b, c)]"},
+		{&ListComp{Elt: a, Generators: []Comprehension{{Target: a, Iter: ifExp(a, b, c), Ifs: []Expr{ifExp(a, b, c)}}}},
+			"[a for a in (b if a else c) if (b if a else c)]"},
+		{&Set{}, "set()"},
+		{&Set{Elts: []Expr{a, tup(b, c)}}, "{a, (b, c)}"},
+		{&BoolOpExpr{Op: Or, Values: []Expr{a, b, c}}, "a or b or c"},
 	}
 	for _, test := range tests {
 		t.Run(test.want, func(t *testing.T) {
@@ -89,3 +106,35 @@ func TestExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestStmts(t *testing.T) {
+	tests := []struct {
+		stmts []Stmt
+		want  string
+	}{
+		{[]Stmt{&Global{Names: []Identifier{a.Id}}}, "global a"},
+		{[]Stmt{&Nonlocal{Names: []Identifier{a.Id, b.Id}}}, "nonlocal a, b"},
+		{[]Stmt{&Raise{}}, "raise"},
+		{[]Stmt{&Raise{Exc: call(a, b)}}, "raise a(b)"},
+		{[]Stmt{&Raise{Exc: a, Cause: b}}, "raise a from b"},
+		{[]Stmt{&With{Items: []WithItem{{ContextExpr: a}}, Body: []Stmt{&ExprStmt{Value: b}}}}, "with a:\n    b"},
+		{[]Stmt{&With{Items: []WithItem{{ContextExpr: a, OptionalVars: b}, {ContextExpr: c}}, Body: []Stmt{&Pass{}}}}, "with a as b, c:\n    pass"},
+		{[]Stmt{&Import{Names: []Alias{{Name: a.Id, Asname: &b.Id}}}}, "import a as b"},
+		{[]Stmt{&ImportFrom{Module: &a.Id, Names: []Alias{{Name: Identifier("*")}}}}, "from a import *"},
+		{[]Stmt{&If{Test: a, Body: []Stmt{&Pass{}}, Orelse: []Stmt{&If{Test: b, Body: []Stmt{&Pass{}}}}}}, "if a:\n    pass\nelif b:\n    pass"},
+		{[]Stmt{&If{Test: a, Body: []Stmt{&Pass{}}, Orelse: []Stmt{&If{Test: b, Body: []Stmt{&Pass{}}}, &ExprStmt{Value: c}}}}, "if a:\n    pass\nelse:\n    if b:\n        pass\n    c"},
+		{[]Stmt{&FunctionDef{Name: a.Id, Body: []Stmt{&Pass{}}, DecoratorList: []Expr{b, attr(c, d)}}}, "\n@b\n@c.d\ndef a():\n    pass"},
+		{[]Stmt{&ClassDef{Name: a.Id, Bases: []Expr{b}, Body: []Stmt{&Pass{}}, DecoratorList: []Expr{c}}}, "\n@c\nclass a(b):\n    pass"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.writeStmts(test.stmts)
+			got := buf.String()
+			if test.want != got {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}